@@ -0,0 +1,392 @@
+package sftpclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Algorithm names supported by golang.org/x/crypto/ssh. The ssh package
+// silently ignores names it does not know, so the options check against these.
+var (
+	supportedCiphers = []string{
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com",
+		"chacha20-poly1305@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+		"aes128-cbc", "3des-cbc",
+		"arcfour256", "arcfour128", "arcfour",
+	}
+
+	supportedKeyExchanges = []string{
+		"curve25519-sha256", "curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512",
+		"diffie-hellman-group14-sha1", "diffie-hellman-group1-sha1",
+		"diffie-hellman-group-exchange-sha256", "diffie-hellman-group-exchange-sha1",
+	}
+
+	supportedMACs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+		"hmac-sha2-256", "hmac-sha2-512", "hmac-sha1", "hmac-sha1-96",
+	}
+
+	supportedHostKeyAlgorithms = []string{
+		ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSAv01,
+		ssh.CertAlgoDSAv01, ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01,
+		ssh.CertAlgoECDSA521v01, ssh.CertAlgoED25519v01,
+		ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+		ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSA,
+		ssh.KeyAlgoDSA, ssh.KeyAlgoED25519,
+	}
+)
+
+// aeadCiphers authenticate the data themselves, so no MAC is used with them.
+var aeadCiphers = map[string]bool{
+	"aes128-gcm@openssh.com":        true,
+	"aes256-gcm@openssh.com":        true,
+	"chacha20-poly1305@openssh.com": true,
+}
+
+// NegotiatedAlgorithms are the algorithms agreed with the SFTP server in the
+// SSH key exchange.
+type NegotiatedAlgorithms struct {
+	KeyExchange string
+	HostKey     string
+	// CipherOut and MACOut protect data sent to the server, CipherIn and MACIn
+	// data received from it. The MAC is empty for AEAD ciphers, which
+	// authenticate the data themselves.
+	CipherOut string
+	CipherIn  string
+	MACOut    string
+	MACIn     string
+}
+
+// WithCiphers restricts the ciphers offered to the server.
+//
+// Parameters:
+// - ciphers: The allowed ciphers, in order of preference, e.g. "aes256-gcm@openssh.com".
+//
+// Returns:
+// - An Option that sets the allowed ciphers.
+func WithCiphers(ciphers ...string) Option {
+	return func(s *OCEOSFTPClient) error {
+		if err := checkAlgorithms("cipher", ciphers, supportedCiphers); err != nil {
+			return err
+		}
+
+		s.config.Ciphers = ciphers
+		return nil
+	}
+}
+
+// WithKeyExchanges restricts the key exchange algorithms offered to the server.
+//
+// Parameters:
+// - kexs: The allowed key exchange algorithms, in order of preference, e.g. "curve25519-sha256".
+//
+// Returns:
+// - An Option that sets the allowed key exchange algorithms.
+func WithKeyExchanges(kexs ...string) Option {
+	return func(s *OCEOSFTPClient) error {
+		if err := checkAlgorithms("key exchange", kexs, supportedKeyExchanges); err != nil {
+			return err
+		}
+
+		s.config.KeyExchanges = kexs
+		return nil
+	}
+}
+
+// WithMACs restricts the MAC algorithms offered to the server.
+//
+// Parameters:
+// - macs: The allowed MAC algorithms, in order of preference, e.g. "hmac-sha2-256-etm@openssh.com".
+//
+// Returns:
+// - An Option that sets the allowed MAC algorithms.
+func WithMACs(macs ...string) Option {
+	return func(s *OCEOSFTPClient) error {
+		if err := checkAlgorithms("MAC", macs, supportedMACs); err != nil {
+			return err
+		}
+
+		s.config.MACs = macs
+		return nil
+	}
+}
+
+// WithHostKeyAlgorithms restricts the host key algorithms accepted from the server.
+//
+// Parameters:
+// - algorithms: The allowed host key algorithms, in order of preference, e.g. "ssh-ed25519".
+//
+// Returns:
+// - An Option that sets the allowed host key algorithms.
+func WithHostKeyAlgorithms(algorithms ...string) Option {
+	return func(s *OCEOSFTPClient) error {
+		if err := checkAlgorithms("host key algorithm", algorithms, supportedHostKeyAlgorithms); err != nil {
+			return err
+		}
+
+		s.config.HostKeyAlgorithms = algorithms
+		return nil
+	}
+}
+
+// WithHardenedAlgorithms restricts the client to modern algorithms: ECDH and
+// Curve25519 or large Diffie-Hellman key exchanges, AES-GCM, ChaCha20-Poly1305
+// or AES-CTR ciphers, SHA-2 MACs and Ed25519, ECDSA or SHA-2 RSA host keys.
+// SHA-1, CBC, RC4 and DSA are never offered.
+//
+// The profile is not FIPS 140 compliant, because Curve25519, ChaCha20-Poly1305
+// and Ed25519 are not approved algorithms. Use WithFIPSAlgorithms instead where
+// that is required.
+//
+// Returns:
+// - An Option that sets the hardened algorithm profile.
+func WithHardenedAlgorithms() Option {
+	return func(s *OCEOSFTPClient) error {
+		s.config.KeyExchanges = []string{
+			"curve25519-sha256", "curve25519-sha256@libssh.org",
+			"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+			"diffie-hellman-group16-sha512", "diffie-hellman-group14-sha256",
+		}
+		s.config.Ciphers = []string{
+			"aes256-gcm@openssh.com", "aes128-gcm@openssh.com",
+			"chacha20-poly1305@openssh.com",
+			"aes256-ctr", "aes192-ctr", "aes128-ctr",
+		}
+		s.config.MACs = []string{
+			"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+			"hmac-sha2-256", "hmac-sha2-512",
+		}
+		s.config.HostKeyAlgorithms = []string{
+			ssh.KeyAlgoED25519,
+			ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+			ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256,
+		}
+		return nil
+	}
+}
+
+// WithFIPSAlgorithms restricts the client to FIPS 140 approved algorithms:
+// NIST curve ECDH or large Diffie-Hellman key exchanges, AES-GCM or AES-CTR
+// ciphers, SHA-2 MACs and ECDSA or SHA-2 RSA host keys.
+//
+// Only the algorithms are restricted. A FIPS 140 deployment also needs the
+// program to be built against a validated cryptographic module.
+//
+// Returns:
+// - An Option that sets the FIPS algorithm profile.
+func WithFIPSAlgorithms() Option {
+	return func(s *OCEOSFTPClient) error {
+		s.config.KeyExchanges = []string{
+			"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+			"diffie-hellman-group16-sha512", "diffie-hellman-group14-sha256",
+		}
+		s.config.Ciphers = []string{
+			"aes256-gcm@openssh.com", "aes128-gcm@openssh.com",
+			"aes256-ctr", "aes192-ctr", "aes128-ctr",
+		}
+		s.config.MACs = []string{
+			"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+			"hmac-sha2-256", "hmac-sha2-512",
+		}
+		s.config.HostKeyAlgorithms = []string{
+			ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+			ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256,
+		}
+		return nil
+	}
+}
+
+// checkAlgorithms checks that names is a non-empty list of supported algorithms.
+func checkAlgorithms(kind string, names, supported []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("missing %s", kind)
+	}
+
+	for _, n := range names {
+		if !contains(supported, n) {
+			return fmt.Errorf("unsupported %s %q", kind, n)
+		}
+	}
+
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// kexRecorder is a net.Conn that records the first SSH_MSG_KEXINIT sent and
+// received, which are not encrypted, so the negotiated algorithms can be
+// worked out once the handshake is done. Once both are recorded it passes
+// reads and writes straight through.
+type kexRecorder struct {
+	net.Conn
+
+	// recorded is set once both directions are done, so the rest of the
+	// connection does not take mu.
+	recorded atomic.Bool
+
+	mu  sync.Mutex
+	out kexInit
+	in  kexInit
+}
+
+func (c *kexRecorder) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if !c.recorded.Load() {
+		c.record(&c.in, p[:n])
+	}
+	return n, err
+}
+
+func (c *kexRecorder) Write(p []byte) (int, error) {
+	if !c.recorded.Load() {
+		c.record(&c.out, p)
+	}
+	return c.Conn.Write(p)
+}
+
+// record adds p to k, one direction of the connection.
+func (c *kexRecorder) record(k *kexInit, p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k.record(p)
+	if c.in.done && c.out.done {
+		c.recorded.Store(true)
+	}
+}
+
+// negotiated returns the algorithms the client and server agreed on. It
+// applies the same rule as the SSH handshake: the first algorithm of the
+// client's list that the server supports.
+func (c *kexRecorder) negotiated() (NegotiatedAlgorithms, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.out.lists == nil || c.in.lists == nil {
+		return NegotiatedAlgorithms{}, errors.New("key exchange not recorded")
+	}
+
+	agree := func(i int) string {
+		for _, a := range c.out.lists[i] {
+			if contains(c.in.lists[i], a) {
+				return a
+			}
+		}
+		return ""
+	}
+
+	algs := NegotiatedAlgorithms{
+		KeyExchange: agree(0),
+		HostKey:     agree(1),
+		CipherOut:   agree(2),
+		CipherIn:    agree(3),
+		MACOut:      agree(4),
+		MACIn:       agree(5),
+	}
+
+	if aeadCiphers[algs.CipherOut] {
+		algs.MACOut = ""
+	}
+
+	if aeadCiphers[algs.CipherIn] {
+		algs.MACIn = ""
+	}
+
+	return algs, nil
+}
+
+const (
+	msgKexInit = 20
+	// maxKexInitRecord bounds how much of a stream is buffered while looking
+	// for its SSH_MSG_KEXINIT.
+	maxKexInitRecord = 64 * 1024
+)
+
+// kexInit parses the algorithm lists of the first SSH_MSG_KEXINIT of one
+// direction of an SSH connection.
+type kexInit struct {
+	buf []byte
+	// lists holds the name-lists of the message once it has been parsed.
+	lists [][]string
+	done  bool
+}
+
+func (k *kexInit) record(p []byte) {
+	if k.done {
+		return
+	}
+
+	k.buf = append(k.buf, p...)
+	if err := k.parse(); err != nil || k.lists != nil || len(k.buf) > maxKexInitRecord {
+		k.done = true
+		k.buf = nil
+	}
+}
+
+// parse skips the identification lines and parses the first binary packet.
+// It returns nil without setting lists if more data is needed.
+func (k *kexInit) parse() error {
+	b := k.buf
+	for {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			return nil
+		}
+
+		line := b[:i]
+		b = b[i+1:]
+		if strings.HasPrefix(string(line), "SSH-") {
+			break
+		}
+	}
+
+	if len(b) < 6 {
+		return nil
+	}
+
+	length := binary.BigEndian.Uint32(b)
+	padding := uint32(b[4])
+	if length > maxKexInitRecord || padding+1 > length {
+		return errors.New("invalid packet")
+	}
+
+	if uint32(len(b)) < 4+length {
+		return nil
+	}
+
+	payload := b[5 : 4+length-padding]
+	if len(payload) < 17 || payload[0] != msgKexInit {
+		return errors.New("first packet is not a key exchange")
+	}
+
+	r := bytes.NewReader(payload[17:])
+	lists := make([][]string, 10)
+	for i := range lists {
+		s, err := readString(r)
+		if err != nil {
+			return err
+		}
+		lists[i] = strings.Split(s, ",")
+	}
+
+	k.lists = lists
+	return nil
+}
@@ -0,0 +1,148 @@
+package sftpclient
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestNegotiatedAlgorithms(t *testing.T) {
+	// The server offers an ECDSA host key besides its Ed25519 one.
+	addECDSA, ecdsaKey := withECDSAHostKey(t)
+	ts := newTestServer(t, addECDSA)
+	hostKeys := WithHostKeyFingerprints(ssh.FingerprintSHA256(ts.hostKey),
+		ssh.FingerprintSHA256(ecdsaKey))
+
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		want NegotiatedAlgorithms
+	}{
+		{
+			name: "restricted",
+			opts: []Option{
+				WithKeyExchanges("ecdh-sha2-nistp384"),
+				WithCiphers("aes256-ctr"),
+				WithMACs("hmac-sha2-512"),
+				WithHostKeyAlgorithms(ssh.KeyAlgoED25519),
+			},
+			want: NegotiatedAlgorithms{
+				KeyExchange: "ecdh-sha2-nistp384",
+				HostKey:     ssh.KeyAlgoED25519,
+				CipherOut:   "aes256-ctr",
+				CipherIn:    "aes256-ctr",
+				MACOut:      "hmac-sha2-512",
+				MACIn:       "hmac-sha2-512",
+			},
+		},
+		{
+			name: "AEAD cipher",
+			opts: []Option{
+				WithCiphers("aes128-gcm@openssh.com"),
+				WithHostKeyAlgorithms(ssh.KeyAlgoECDSA256),
+				WithKeyExchanges("diffie-hellman-group14-sha256"),
+			},
+			want: NegotiatedAlgorithms{
+				KeyExchange: "diffie-hellman-group14-sha256",
+				HostKey:     ssh.KeyAlgoECDSA256,
+				CipherOut:   "aes128-gcm@openssh.com",
+				CipherIn:    "aes128-gcm@openssh.com",
+			},
+		},
+		{
+			name: "hardened",
+			opts: []Option{WithHardenedAlgorithms()},
+			want: NegotiatedAlgorithms{
+				KeyExchange: "curve25519-sha256",
+				HostKey:     ssh.KeyAlgoED25519,
+				CipherOut:   "aes256-gcm@openssh.com",
+				CipherIn:    "aes256-gcm@openssh.com",
+			},
+		},
+		{
+			name: "FIPS",
+			opts: []Option{WithFIPSAlgorithms()},
+			want: NegotiatedAlgorithms{
+				KeyExchange: "ecdh-sha2-nistp256",
+				HostKey:     ssh.KeyAlgoECDSA256,
+				CipherOut:   "aes256-gcm@openssh.com",
+				CipherIn:    "aes256-gcm@openssh.com",
+			},
+		},
+	} {
+		var got []NegotiatedAlgorithms
+		opts := append([]Option{hostKeys, WithUploadCallback(func(r UploadResult) {
+			got = append(got, r.Algorithms)
+		})}, tt.opts...)
+		c := ts.client(t, opts...)
+
+		if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%s: negotiated %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKexRecorderPassesThroughAfterHandshake(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, WithCiphers("aes128-ctr"))
+
+	nc, err := net.Dial("tcp", ts.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+
+	config := c.config
+	auth, release, err := c.authMethods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Auth = auth
+
+	rec := &kexRecorder{Conn: nc}
+	conn, chans, reqs, err := ssh.NewClientConn(rec, ts.addr, &config)
+	release()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ssh.NewClient(conn, chans, reqs).Close()
+
+	if !rec.recorded.Load() {
+		t.Fatal("recorder still inspects the connection after the handshake")
+	}
+
+	if rec.in.buf != nil || rec.out.buf != nil {
+		t.Error("recorder kept its buffers after the handshake")
+	}
+
+	algs, err := rec.negotiated()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if algs.CipherOut != "aes128-ctr" || algs.CipherIn != "aes128-ctr" {
+		t.Errorf("negotiated ciphers %s and %s, want aes128-ctr", algs.CipherOut, algs.CipherIn)
+	}
+}
+
+func TestAlgorithmOptionErrors(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"no ciphers":        WithCiphers(),
+		"unknown cipher":    WithCiphers("rot13"),
+		"unknown kex":       WithKeyExchanges("sntrup761x25519-sha512@openssh.com"),
+		"unknown MAC":       WithMACs("umac-64@openssh.com"),
+		"unknown host key":  WithHostKeyAlgorithms("ssh-xmss@openssh.com"),
+		"no host key algos": WithHostKeyAlgorithms(),
+	} {
+		if _, err := NewOCEOSFTPCLient("localhost", "22", "test", nil, WithInsecureIgnoreHostKey(), WithPassword("pw"), opt); err == nil {
+			t.Errorf("%s: NewOCEOSFTPCLient succeeded, want an error", name)
+		}
+	}
+}
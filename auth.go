@@ -0,0 +1,198 @@
+package sftpclient
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// AuthMethod names a way of authenticating to the SFTP server.
+type AuthMethod string
+
+const (
+	// AuthPublicKey authenticates with the private key passed to NewOCEOSFTPCLient.
+	AuthPublicKey AuthMethod = "publickey"
+	// AuthAgent authenticates with the keys held by an ssh-agent. See WithSSHAgent.
+	AuthAgent AuthMethod = "agent"
+	// AuthKeyboardInteractive answers the server's prompts. See WithKeyboardInteractive.
+	AuthKeyboardInteractive AuthMethod = "keyboard-interactive"
+	// AuthPassword authenticates with a password. See WithPassword.
+	AuthPassword AuthMethod = "password"
+)
+
+// defaultAuthOrder is the order in which configured methods are tried unless
+// WithAuthOrder is used.
+var defaultAuthOrder = []AuthMethod{
+	AuthPublicKey,
+	AuthAgent,
+	AuthKeyboardInteractive,
+	AuthPassword,
+}
+
+// WithPassword authenticates with a password.
+//
+// Parameters:
+// - password: The password of the user.
+//
+// Returns:
+// - An Option that adds password authentication.
+func WithPassword(password string) Option {
+	return func(s *OCEOSFTPClient) error {
+		if len(password) == 0 {
+			return errors.New("missing password")
+		}
+
+		s.auth[AuthPassword] = ssh.Password(password)
+		return nil
+	}
+}
+
+// WithKeyboardInteractive authenticates by answering the questions the
+// server asks, which is how many servers prompt for passwords and one-time
+// codes.
+//
+// Parameters:
+// - challenge: Answers the server's questions.
+//
+// Returns:
+// - An Option that adds keyboard-interactive authentication.
+func WithKeyboardInteractive(challenge ssh.KeyboardInteractiveChallenge) Option {
+	return func(s *OCEOSFTPClient) error {
+		if challenge == nil {
+			return errors.New("missing keyboard interactive challenge")
+		}
+
+		s.auth[AuthKeyboardInteractive] = ssh.KeyboardInteractive(challenge)
+		return nil
+	}
+}
+
+// WithSSHAgent authenticates with the keys held by the ssh-agent listening on
+// the SSH_AUTH_SOCK socket. The agent is contacted on every connection, and
+// skipped if it cannot be reached while other methods are configured.
+//
+// Returns:
+// - An Option that adds ssh-agent authentication.
+func WithSSHAgent() Option {
+	return func(s *OCEOSFTPClient) error {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if len(sock) == 0 {
+			return errors.New("SSH_AUTH_SOCK is not set")
+		}
+
+		s.agentSock = sock
+		return nil
+	}
+}
+
+// WithAuthOrder sets the order in which authentication methods are tried.
+// Configured methods that are not listed are not used. By default methods
+// are tried in the order public key, agent, keyboard-interactive, password.
+//
+// Parameters:
+// - methods: The methods to try, in order.
+//
+// Returns:
+// - An Option that sets the order of authentication methods.
+func WithAuthOrder(methods ...AuthMethod) Option {
+	return func(s *OCEOSFTPClient) error {
+		if len(methods) == 0 {
+			return errors.New("missing auth methods")
+		}
+
+		seen := make(map[AuthMethod]bool, len(methods))
+		for _, m := range methods {
+			switch m {
+			case AuthPublicKey, AuthAgent, AuthKeyboardInteractive, AuthPassword:
+			default:
+				return fmt.Errorf("unknown auth method %q", m)
+			}
+
+			if seen[m] {
+				return fmt.Errorf("duplicate auth method %q", m)
+			}
+			seen[m] = true
+		}
+
+		s.authOrder = methods
+		return nil
+	}
+}
+
+// configured reports whether the auth method m has been set up.
+func (s *OCEOSFTPClient) configured(m AuthMethod) bool {
+	if m == AuthAgent {
+		return len(s.agentSock) > 0
+	}
+	return s.auth[m] != nil
+}
+
+// checkAuth checks that at least one authentication method will be tried and
+// that every method in a custom order has been set up.
+func (s *OCEOSFTPClient) checkAuth() error {
+	if s.authOrder == nil {
+		for _, m := range defaultAuthOrder {
+			if s.configured(m) {
+				return nil
+			}
+		}
+		return errors.New("missing authentication method")
+	}
+
+	for _, m := range s.authOrder {
+		if !s.configured(m) {
+			return fmt.Errorf("auth method %q is not configured", m)
+		}
+	}
+
+	return nil
+}
+
+// authMethods returns the configured authentication methods in order. The
+// returned func releases the ssh-agent connection, if any, and must be
+// called once the handshake is done.
+func (s *OCEOSFTPClient) authMethods() ([]ssh.AuthMethod, func(), error) {
+	order := s.authOrder
+	if order == nil {
+		order = defaultAuthOrder
+	}
+
+	var methods []ssh.AuthMethod
+	var agentConn net.Conn
+	for _, m := range order {
+		if m != AuthAgent {
+			if am := s.auth[m]; am != nil {
+				methods = append(methods, am)
+			}
+			continue
+		}
+
+		if len(s.agentSock) == 0 {
+			continue
+		}
+
+		conn, err := net.Dial("unix", s.agentSock)
+		if err != nil {
+			s.logger.Warn("failed to connect to ssh-agent", slog.Any(logKeyError, err))
+			continue
+		}
+
+		agentConn = conn
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	if len(methods) == 0 {
+		return nil, nil, errors.New("no authentication method available")
+	}
+
+	return methods, func() {
+		if agentConn != nil {
+			_ = agentConn.Close()
+		}
+	}, nil
+}
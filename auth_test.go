@@ -0,0 +1,194 @@
+package sftpclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var errAuthFailed = errors.New("authentication failed")
+
+func withPassword(password string) func(*ssh.ServerConfig) {
+	return func(config *ssh.ServerConfig) {
+		config.PasswordCallback = func(_ ssh.ConnMetadata, got []byte) (*ssh.Permissions, error) {
+			if string(got) != password {
+				return nil, errAuthFailed
+			}
+			return nil, nil
+		}
+	}
+}
+
+func TestPasswordAuth(t *testing.T) {
+	ts := newTestServer(t, withPassword("secret"))
+
+	c := ts.clientWithKey(t, nil, WithPassword("secret"))
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatalf("upload with the right password failed: %v", err)
+	}
+
+	c = ts.clientWithKey(t, nil, WithPassword("wrong"), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err == nil {
+		t.Fatal("upload with a wrong password succeeded")
+	}
+}
+
+func TestKeyboardInteractiveAuth(t *testing.T) {
+	ts := newTestServer(t, func(config *ssh.ServerConfig) {
+		config.KeyboardInteractiveCallback = func(_ ssh.ConnMetadata,
+			client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := client("", "", []string{"Password: ", "Code: "}, []bool{false, true})
+			if err != nil {
+				return nil, err
+			}
+
+			if len(answers) != 2 || answers[0] != "secret" || answers[1] != "123456" {
+				return nil, errAuthFailed
+			}
+			return nil, nil
+		}
+	})
+
+	var questions []string
+	c := ts.clientWithKey(t, nil, WithKeyboardInteractive(
+		func(_, _ string, q []string, _ []bool) ([]string, error) {
+			questions = append(questions, q...)
+			return []string{"secret", "123456"}, nil
+		}))
+
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"Password: ", "Code: "}; !reflect.DeepEqual(questions, want) {
+		t.Errorf("asked %q, want %q", questions, want)
+	}
+}
+
+// serveAgent serves an ssh-agent holding key on a unix socket and points
+// SSH_AUTH_SOCK at it.
+func serveAgent(t *testing.T, key []byte) {
+	t.Helper()
+
+	priv, err := ssh.ParseRawPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatal(err)
+	}
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", sock)
+}
+
+func TestSSHAgentAuth(t *testing.T) {
+	ts := newTestServer(t)
+	serveAgent(t, ts.key)
+
+	c := ts.clientWithKey(t, nil, WithSSHAgent())
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSSHAgentUnreachable(t *testing.T) {
+	ts := newTestServer(t, withPassword("secret"))
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(t.TempDir(), "missing.sock"))
+
+	// The agent is skipped because another method is configured.
+	c := ts.clientWithKey(t, nil, WithSSHAgent(), WithPassword("secret"))
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthOrder(t *testing.T) {
+	var mu sync.Mutex
+	var tried []string
+	ts := newTestServer(t, withPassword("secret"), func(config *ssh.ServerConfig) {
+		config.AuthLogCallback = func(_ ssh.ConnMetadata, method string, _ error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if method != "none" {
+				tried = append(tried, method)
+			}
+		}
+	})
+
+	// A wrong password makes the client fall back to its key.
+	for _, tt := range []struct {
+		name     string
+		password string
+		order    []AuthMethod
+		want     []string
+	}{
+		{"default", "secret", nil, []string{"publickey"}},
+		{"password first", "secret", []AuthMethod{AuthPassword, AuthPublicKey}, []string{"password"}},
+		{"fall back to key", "wrong", []AuthMethod{AuthPassword, AuthPublicKey}, []string{"password", "publickey"}},
+	} {
+		opts := []Option{WithPassword(tt.password)}
+		if tt.order != nil {
+			opts = append(opts, WithAuthOrder(tt.order...))
+		}
+
+		mu.Lock()
+		tried = nil
+		mu.Unlock()
+
+		c := ts.client(t, opts...)
+		if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		mu.Lock()
+		got := tried
+		mu.Unlock()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: tried %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAuthConfigErrors(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"no method":      nil,
+		"unconfigured":   {WithPassword("secret"), WithAuthOrder(AuthPassword, AuthKeyboardInteractive)},
+		"duplicate":      {WithPassword("secret"), WithAuthOrder(AuthPassword, AuthPassword)},
+		"unknown":        {WithPassword("secret"), WithAuthOrder("gssapi")},
+		"empty password": {WithPassword("")},
+	} {
+		if _, err := NewOCEOSFTPCLient("localhost", "22", "test", nil,
+			append([]Option{WithInsecureIgnoreHostKey()}, opts...)...); err == nil {
+			t.Errorf("%s: NewOCEOSFTPCLient succeeded, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package sftpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Maritime-AI/oceo-sftp-csv-go/models"
+	"github.com/gocarina/gocsv"
+)
+
+// FileTypeManifest is the file type of the manifest written by UploadBundle.
+const FileTypeManifest FileType = "manifest"
+
+// ManifestEntry describes one file of a bundle as it is stored on the server.
+type ManifestEntry struct {
+	// FileName is the path of the file relative to the remote directory.
+	FileName string   `csv:"File Name" json:"file_name"`
+	FileType FileType `csv:"File Type" json:"file_type"`
+	RowCount int      `csv:"Row Count" json:"row_count"`
+	ByteSize int64    `csv:"Byte Size" json:"byte_size"`
+	SHA256   string   `csv:"SHA256" json:"sha256"`
+}
+
+// Manifest lists the files of a bundle uploaded by UploadBundle.
+type Manifest struct {
+	// FileName is the path of the manifest relative to the remote directory.
+	FileName string
+	Entries  []ManifestEntry
+	// Algorithms are the SSH algorithms of the connection the manifest was
+	// sent over.
+	Algorithms NegotiatedAlgorithms
+}
+
+// UploadBundle uploads every non-empty file type of ds over a single
+// connection and then writes a manifest listing each file, or each part of a
+// file if the client splits uploads. Because the manifest is written last,
+// the server can wait for it before ingesting a consistent snapshot.
+//
+// All records are validated before anything is uploaded. Use
+// models.CheckIntegrity to also check the references between files.
+//
+// Parameters:
+// - orgName: The name of your organization.
+// - ds: The records to upload.
+//
+// Returns:
+// - The manifest that was uploaded.
+// - An error if validation or any upload fails. Validation errors of all file
+// types are joined.
+func (s *OCEOSFTPClient) UploadBundle(ctx context.Context,
+	orgName string, ds *models.Dataset) (*Manifest, error) {
+	if ds == nil {
+		return nil, errors.New("missing dataset")
+	}
+
+	t := time.Now()
+	var files []*file
+	var errs []error
+	add := func(fs []*file, err error) {
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		files = append(files, fs...)
+	}
+
+	add(bundleFile(s, orgName, t, pointers(ds.Crew)))
+	add(bundleFile(s, orgName, t, pointers(ds.CrewCredentials)))
+	add(bundleFile(s, orgName, t, pointers(ds.CrewSeatime)))
+	add(bundleFile(s, orgName, t, pointers(ds.Vessels)))
+	add(bundleFile(s, orgName, t, pointers(ds.VesselSchedules)))
+	add(bundleFile(s, orgName, t, pointers(ds.VesselSchedulePositions)))
+	add(bundleFile(s, orgName, t, pointers(ds.CrewSchedules)))
+	add(bundleFile(s, orgName, t, pointers(ds.CrewSchedulePositions)))
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("no records to upload")
+	}
+
+	entries, err := s.uploadFiles(ctx, orgName, files)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.uploadManifest(ctx, orgName, t, entries)
+}
+
+// uploadManifest uploads a manifest listing entries, named as a file of
+// FileTypeManifest uploaded by orgName at t.
+func (s *OCEOSFTPClient) uploadManifest(ctx context.Context, orgName string,
+	t time.Time, entries []ManifestEntry) (*Manifest, error) {
+	name, err := s.relativePath(orgName, FileTypeManifest, t)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := gocsv.MarshalBytes(&entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	start := time.Now()
+	dest := s.remotePath(name)
+	up, err := s.uploadData(ctx, dest, bs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload manifest: %w", err)
+	}
+	s.logUpload(ctx, orgName, FileTypeManifest, dest, len(entries), up, start)
+
+	return &Manifest{
+		FileName:   name,
+		Entries:    entries,
+		Algorithms: up.algorithms,
+	}, nil
+}
+
+// bundleFile marshals records for a bundle. It returns no files if there are
+// no records.
+func bundleFile[T models.Record](s *OCEOSFTPClient, orgName string,
+	t time.Time, records []T) ([]*file, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return marshalFiles(s, orgName, t, records)
+}
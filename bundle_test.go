@@ -0,0 +1,149 @@
+package sftpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Maritime-AI/oceo-sftp-csv-go/models"
+	"github.com/gocarina/gocsv"
+)
+
+func TestUploadBundle(t *testing.T) {
+	ts := newTestServer(t)
+
+	var mu sync.Mutex
+	var uploaded []string
+	c := ts.client(t, WithFileNameFunc(fixedFileName), WithUploadCallback(func(r UploadResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		uploaded = append(uploaded, r.RemotePath)
+	}))
+
+	ds := &models.Dataset{
+		Crew: []models.Crew{*testCrew(1), *testCrew(2)},
+		CrewCredentials: []models.CrewCredential{
+			{ContextID: "ctx", CrewExternalID: "crew-1", Title: "Master"},
+		},
+		Vessels: []models.Vessel{
+			{ContextID: "ctx", ExternalID: "v-1", VesselExternalID: "V1", Name: "Example"},
+			{ContextID: "ctx", ExternalID: "v-2", VesselExternalID: "V2", Name: "Other"},
+			{ContextID: "ctx", ExternalID: "v-3", VesselExternalID: "V3", Name: "Third"},
+		},
+	}
+
+	m, err := c.UploadBundle(context.Background(), "org", ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := m.FileName, "org_manifest.csv"; got != want {
+		t.Errorf("manifest name = %q, want %q", got, want)
+	}
+
+	want := []struct {
+		fileType FileType
+		rows     int
+	}{
+		{FileTypeCrew, 2},
+		{FileTypeCrewCredentials, 1},
+		{FileTypeVessels, 3},
+	}
+	if len(m.Entries) != len(want) {
+		t.Fatalf("manifest has %d entries, want %d: %+v", len(m.Entries), len(want), m.Entries)
+	}
+
+	for i, w := range want {
+		e := m.Entries[i]
+		if e.FileType != w.fileType || e.RowCount != w.rows {
+			t.Errorf("entry %d = %s with %d rows, want %s with %d rows",
+				i, e.FileType, e.RowCount, w.fileType, w.rows)
+		}
+
+		if e.FileName != fixedFileName("org", w.fileType, time.Time{}) {
+			t.Errorf("entry %d is named %q", i, e.FileName)
+		}
+
+		data, err := os.ReadFile(filepath.Join(ts.root, "data", e.FileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sum := sha256.Sum256(data)
+		if e.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("entry %d SHA-256 = %s, want %x", i, e.SHA256, sum)
+		}
+
+		if e.ByteSize != int64(len(data)) {
+			t.Errorf("entry %d byte size = %d, want %d", i, e.ByteSize, len(data))
+		}
+
+		rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows)-1 != e.RowCount {
+			t.Errorf("entry %d row count = %d, file has %d rows", i, e.RowCount, len(rows)-1)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(ts.root, "data", m.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []ManifestEntry
+	if err := gocsv.UnmarshalBytes(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entries, m.Entries) {
+		t.Errorf("manifest on the server = %+v, want %+v", entries, m.Entries)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(uploaded) != len(want)+1 || uploaded[len(uploaded)-1] != "data/"+m.FileName {
+		t.Errorf("uploaded %q, want the manifest last", uploaded)
+	}
+}
+
+func TestUploadBundleValidation(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, WithUploadCallback(func(r UploadResult) {
+		t.Errorf("uploaded %s", r.RemotePath)
+	}))
+
+	invalidCrew := *testCrew(1)
+	invalidCrew.FirstName = ""
+	ds := &models.Dataset{
+		Crew:    []models.Crew{*testCrew(2), invalidCrew},
+		Vessels: []models.Vessel{{ContextID: "ctx", ExternalID: "v-1", VesselExternalID: "V1"}},
+		CrewCredentials: []models.CrewCredential{
+			{ContextID: "ctx", CrewExternalID: "crew-1", Title: "Master"},
+		},
+	}
+
+	_, err := c.UploadBundle(context.Background(), "org", ds)
+	if err == nil {
+		t.Fatal("UploadBundle accepted invalid records")
+	}
+
+	for _, want := range []string{"missing first name", "missing vessel name"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(ts.root, "data")); !os.IsNotExist(err) {
+		t.Errorf("UploadBundle created the remote directory: %v", err)
+	}
+}
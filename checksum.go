@@ -0,0 +1,211 @@
+package sftpclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// checksumSuffix is appended to the name of a file to name its sidecar.
+	checksumSuffix = ".sha256"
+
+	// checkFileExtension is the SFTP extension for hashing a file on the server.
+	checkFileExtension = "check-file"
+
+	sftpPacketInit          = 1
+	sftpPacketVersion       = 2
+	sftpPacketStatus        = 101
+	sftpPacketExtended      = 200
+	sftpPacketExtendedReply = 201
+
+	// maxSFTPReply bounds the size of the replies read by remoteSHA256.
+	maxSFTPReply = 64 * 1024
+)
+
+// ErrChecksumMismatch is returned when the SHA-256 of an uploaded file
+// computed by the server differs from the one computed while uploading.
+var ErrChecksumMismatch = errors.New("remote checksum mismatch")
+
+// WithChecksumFile writes a "<file>.sha256" sidecar next to every uploaded
+// file. It holds the SHA-256 of the file as stored on the server, in the
+// format of sha256sum.
+//
+// Returns:
+// - An Option that enables checksum sidecars.
+func WithChecksumFile() Option {
+	return func(s *OCEOSFTPClient) error {
+		s.checksumFile = true
+		return nil
+	}
+}
+
+// checksumLine returns the sidecar content for a file named name.
+func checksumLine(sum, name string) []byte {
+	return []byte(fmt.Sprintf("%s  %s\n", sum, path.Base(name)))
+}
+
+// verifySHA256 compares want with the SHA-256 of p computed by the server.
+// It does nothing if the server does not support the check-file extension.
+func verifySHA256(sess *session, p, want string) error {
+	if _, ok := sess.sc.HasExtension(checkFileExtension); !ok {
+		return nil
+	}
+
+	got, err := remoteSHA256(sess.conn, p)
+	if err != nil {
+		return fmt.Errorf("failed to hash remote file: %w", err)
+	}
+
+	if hex.EncodeToString(got) != want {
+		return fmt.Errorf("%w: %s: got %x, want %s", ErrChecksumMismatch, p, got, want)
+	}
+
+	return nil
+}
+
+// remoteSHA256 asks the server for the SHA-256 of the file at p using the
+// check-file-name request. The request is sent on its own SFTP channel
+// because the SFTP client does not expose custom extended requests.
+func remoteSHA256(conn *ssh.Client, p string) ([]byte, error) {
+	ch, reqs, err := conn.OpenChannel("session", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+	go ssh.DiscardRequests(reqs)
+
+	ok, err := ch.SendRequest("subsystem", true, ssh.Marshal(struct{ Name string }{"sftp"}))
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, errors.New("sftp subsystem request rejected")
+	}
+
+	var init bytes.Buffer
+	writeUint32(&init, 3)
+	if err := writeSFTPPacket(ch, sftpPacketInit, init.Bytes()); err != nil {
+		return nil, err
+	}
+
+	typ, _, err := readSFTPPacket(ch)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ != sftpPacketVersion {
+		return nil, fmt.Errorf("unexpected sftp packet type %d", typ)
+	}
+
+	const id = 1
+	var req bytes.Buffer
+	writeUint32(&req, id)
+	writeString(&req, "check-file-name")
+	writeString(&req, p)
+	writeString(&req, "sha256")
+	_ = binary.Write(&req, binary.BigEndian, uint64(0)) // start offset
+	_ = binary.Write(&req, binary.BigEndian, uint64(0)) // length, 0 means to the end
+	writeUint32(&req, 0)                                // block size, 0 means one hash
+	if err := writeSFTPPacket(ch, sftpPacketExtended, req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	typ, data, err := readSFTPPacket(ch)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bytes.NewReader(data)
+	if gotID, err := readUint32(r); err != nil || gotID != id {
+		return nil, errors.New("malformed sftp reply")
+	}
+
+	switch typ {
+	case sftpPacketExtendedReply:
+		algo, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if algo != "sha256" {
+			return nil, fmt.Errorf("server hashed with %s instead of sha256", algo)
+		}
+
+		sum, _ := io.ReadAll(r)
+		if len(sum) != 32 {
+			return nil, fmt.Errorf("malformed sha256 of %d bytes", len(sum))
+		}
+		return sum, nil
+	case sftpPacketStatus:
+		code, _ := readUint32(r)
+		msg, _ := readString(r)
+		return nil, fmt.Errorf("check-file failed with status %d: %s", code, msg)
+	default:
+		return nil, fmt.Errorf("unexpected sftp packet type %d", typ)
+	}
+}
+
+func writeSFTPPacket(w io.Writer, typ byte, payload []byte) error {
+	var b bytes.Buffer
+	writeUint32(&b, uint32(len(payload)+1))
+	b.WriteByte(typ)
+	b.Write(payload)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func readSFTPPacket(r io.Reader) (byte, []byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return 0, nil, err
+	}
+
+	if n == 0 || n > maxSFTPReply {
+		return 0, nil, fmt.Errorf("invalid sftp packet length %d", n)
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, nil, err
+	}
+
+	return b[0], b[1:], nil
+}
+
+func writeUint32(b *bytes.Buffer, v uint32) {
+	_ = binary.Write(b, binary.BigEndian, v)
+}
+
+func writeString(b *bytes.Buffer, s string) {
+	writeUint32(b, uint32(len(s)))
+	b.WriteString(s)
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var v uint32
+	err := binary.Read(r, binary.BigEndian, &v)
+	return v, err
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	n, err := readUint32(r)
+	if err != nil {
+		return "", err
+	}
+
+	if int64(n) > int64(r.Len()) {
+		return "", io.ErrUnexpectedEOF
+	}
+
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	return string(b), err
+}
@@ -0,0 +1,136 @@
+package sftpclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// hashRemoteFile returns a check-file handler for ts that hashes the
+// requested file and counts the requests in calls.
+func hashRemoteFile(ts *testServer, calls *atomic.Int32) func(p string) ([]byte, error) {
+	return func(p string) ([]byte, error) {
+		calls.Add(1)
+		data, err := os.ReadFile(filepath.Join(ts.root, p))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		return sum[:], nil
+	}
+}
+
+// remoteFiles returns the names of the files in the data directory of ts.
+func remoteFiles(t *testing.T, ts *testServer) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(filepath.Join(ts.root, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	return names
+}
+
+func TestChecksumFile(t *testing.T) {
+	ts := newTestServer(t)
+	var calls atomic.Int32
+	ts.handleCheckFile(hashRemoteFile(ts, &calls))
+	c := ts.client(t, WithChecksumFile(), WithFileNameFunc(fixedFileName))
+
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file and its sidecar are both verified by the server.
+	if got := calls.Load(); got != 2 {
+		t.Errorf("server hashed %d files, want 2", got)
+	}
+
+	data, err := os.ReadFile(filepath.Join(ts.root, "data", "org_crew.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sidecar, err := os.ReadFile(filepath.Join(ts.root, "data", "org_crew.csv"+checksumSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(data)
+	if got, want := string(sidecar), hex.EncodeToString(sum[:])+"  org_crew.csv\n"; got != want {
+		t.Errorf("sidecar = %q, want %q", got, want)
+	}
+
+	if got := remoteFiles(t, ts); len(got) != 2 {
+		t.Errorf("remote files = %q, want the file and its sidecar", got)
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	ts := newTestServer(t)
+	ts.handleCheckFile(func(string) ([]byte, error) {
+		return make([]byte, sha256.Size), nil
+	})
+	c := ts.client(t, WithChecksumFile(), noRetry)
+
+	err := Upload(context.Background(), c, "org", testCrew(1))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Upload returned %v, want ErrChecksumMismatch", err)
+	}
+
+	if got := remoteFiles(t, ts); len(got) != 0 {
+		t.Errorf("remote files = %q, want the temporary file removed", got)
+	}
+}
+
+func TestChecksumServerError(t *testing.T) {
+	ts := newTestServer(t)
+	ts.handleCheckFile(func(string) ([]byte, error) {
+		return nil, errors.New("hashing failed")
+	})
+	c := ts.client(t, noRetry)
+
+	err := Upload(context.Background(), c, "org", testCrew(1))
+	if err == nil || !strings.Contains(err.Error(), "hashing failed") {
+		t.Fatalf("Upload returned %v, want the server's check-file error", err)
+	}
+
+	if got := remoteFiles(t, ts); len(got) != 0 {
+		t.Errorf("remote files = %q, want the temporary file removed", got)
+	}
+}
+
+func TestChecksumUnsupported(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, WithChecksumFile(), WithFileNameFunc(fixedFileName))
+
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(ts.root, "data", "org_crew.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sidecar, err := os.ReadFile(filepath.Join(ts.root, "data", "org_crew.csv"+checksumSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(data)
+	if !strings.HasPrefix(string(sidecar), hex.EncodeToString(sum[:])) {
+		t.Errorf("sidecar = %q, want the SHA-256 computed while uploading", sidecar)
+	}
+}
@@ -0,0 +1,32 @@
+package sftpclient
+
+import (
+	"compress/gzip"
+	"fmt"
+)
+
+const (
+	// gzipSuffix is appended to the names of compressed files.
+	gzipSuffix = ".gz"
+)
+
+// WithGzip compresses uploaded files with gzip. Compressed files get a ".gz"
+// suffix, e.g. "org_crew_1700000000_0a1b2c3d4e5f.csv.gz".
+//
+// Parameters:
+// - level: The compression level, from gzip.HuffmanOnly to gzip.BestCompression.
+// Use gzip.DefaultCompression for the default level.
+//
+// Returns:
+// - An Option that enables compression.
+func WithGzip(level int) Option {
+	return func(s *OCEOSFTPClient) error {
+		if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+			return fmt.Errorf("invalid gzip compression level %d", level)
+		}
+
+		s.gzip = true
+		s.gzipLevel = level
+		return nil
+	}
+}
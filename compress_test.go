@@ -0,0 +1,117 @@
+package sftpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Maritime-AI/oceo-sftp-csv-go/models"
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// gunzip decompresses data, failing the test if it is not gzip.
+func gunzip(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("not gzip: %v", err)
+	}
+
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("failed to decompress: %v", err)
+	}
+	return out
+}
+
+func TestGzipUpload(t *testing.T) {
+	const rows = 50
+
+	ts := newTestServer(t)
+	c := ts.client(t, WithGzip(gzip.BestCompression), WithFileNameFunc(fixedFileName))
+	ctx := context.Background()
+	want := crewCSV(t, rows)
+
+	records := make([]*models.Crew, rows)
+	for i := range records {
+		records[i] = testCrew(i + 1)
+	}
+
+	for name, upload := range map[string]func() error{
+		"Upload":     func() error { return Upload(ctx, c, "org", records...) },
+		"UploadIter": func() error { return UploadIter(ctx, c, "org", crewIter(rows)) },
+		"UploadCSV": func() error {
+			return UploadCSV[*models.Crew](ctx, c, "org", bytes.NewReader(want))
+		},
+	} {
+		if err := upload(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(ts.root, "data", "org_crew.csv.gz"))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if got := gunzip(t, data); !bytes.Equal(got, want) {
+			t.Errorf("%s: decompressed file = %q, want %q", name, got, want)
+		}
+
+		if len(data) >= len(want) {
+			t.Errorf("%s: compressed file has %d bytes, CSV has %d", name, len(data), len(want))
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(ts.root, "data", "org_crew.csv")); !os.IsNotExist(err) {
+		t.Errorf("uncompressed file exists: %v", err)
+	}
+}
+
+func TestGzipOpenPGPUpload(t *testing.T) {
+	recipient, recipientPub, _ := newPGPKey(t, "oceo", nil)
+
+	ts := newTestServer(t)
+	c := ts.client(t, WithGzip(gzip.DefaultCompression), WithFileNameFunc(fixedFileName),
+		WithOpenPGP(PGPConfig{Recipients: [][]byte{recipientPub}}))
+
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(ts.root, "data", "org_crew.csv.gz"+pgpSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Compressing after encrypting would find nothing to compress, so the
+	// encrypted file must hold the compressed CSV.
+	md, err := openpgp.ReadMessage(bytes.NewReader(data), openpgp.EntityList{recipient}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+
+	plaintext, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+
+	got := gunzip(t, plaintext)
+	if want := crewCSV(t, 1); !bytes.Equal(got, want) {
+		t.Errorf("decrypted and decompressed file = %q, want %q", got, want)
+	}
+}
+
+func TestGzipLevel(t *testing.T) {
+	for _, level := range []int{gzip.HuffmanOnly - 1, gzip.BestCompression + 1} {
+		_, err := NewOCEOSFTPCLient("localhost", "22", "test", nil, WithInsecureIgnoreHostKey(), WithGzip(level))
+		if err == nil || !strings.Contains(err.Error(), "gzip compression level") {
+			t.Errorf("WithGzip(%d) returned %v, want a level error", level, err)
+		}
+	}
+}
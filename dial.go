@@ -0,0 +1,197 @@
+package sftpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Dialer opens network connections. *net.Dialer implements it.
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DialerFunc adapts a function to a Dialer.
+type DialerFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// DialContext calls f.
+func (f DialerFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+// JumpHost is an SSH server that connections are tunnelled through, like
+// OpenSSH's ProxyJump.
+type JumpHost struct {
+	// Addr is the "host:port" of the jump host.
+	Addr string
+	User string
+	// Auth holds the methods used to authenticate to the jump host, e.g.
+	// ssh.PublicKeys(signer).
+	Auth []ssh.AuthMethod
+	// HostKeyCallback verifies the jump host's key, e.g. the callback returned
+	// by knownhosts.New. Use ssh.InsecureIgnoreHostKey to skip verification.
+	HostKeyCallback ssh.HostKeyCallback
+}
+
+// WithDialer opens the connection to the SFTP server, or to the first jump
+// host, with d instead of a plain TCP connection.
+//
+// Parameters:
+// - d: The dialer to use.
+//
+// Returns:
+// - An Option that sets the dialer.
+func WithDialer(d Dialer) Option {
+	return func(s *OCEOSFTPClient) error {
+		if d == nil {
+			return errors.New("missing dialer")
+		}
+
+		s.dialer = d
+		return nil
+	}
+}
+
+// WithJumpHost tunnels the connection through an SSH jump host. Calling it
+// more than once chains jump hosts in order, each one reached through the
+// previous one. The first jump host is reached with the client's dialer, so
+// jump hosts can be combined with a proxy.
+//
+// Jump hosts are held to the same algorithms as the SFTP server, e.g. those
+// set by WithHardenedAlgorithms. A host key that fails verification with a
+// knownhosts callback is reported as a *HostKeyError.
+//
+// Parameters:
+// - hop: The jump host, with its own credentials and host key verification.
+//
+// Returns:
+// - An Option that adds a jump host.
+func WithJumpHost(hop JumpHost) Option {
+	return func(s *OCEOSFTPClient) error {
+		if _, _, err := net.SplitHostPort(hop.Addr); err != nil {
+			return fmt.Errorf("invalid jump host address %q: %w", hop.Addr, err)
+		}
+
+		if len(hop.Auth) == 0 {
+			return fmt.Errorf("missing auth for jump host %s", hop.Addr)
+		}
+
+		if hop.HostKeyCallback == nil {
+			return fmt.Errorf("missing host key callback for jump host %s", hop.Addr)
+		}
+
+		s.jumpHosts = append(s.jumpHosts, hop)
+		return nil
+	}
+}
+
+// dial opens a connection to the SFTP server through the client's dialer and
+// jump hosts. Closing the connection also closes the jump host connections.
+func (s *OCEOSFTPClient) dial(ctx context.Context) (net.Conn, error) {
+	addr := s.addr
+	if len(s.jumpHosts) > 0 {
+		addr = s.jumpHosts[0].Addr
+	}
+
+	nc, err := dialTimeout(ctx, s.dialer, addr, s.dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed SSH handshake closes the connection it ran over, so only the
+	// jump hosts reached so far need closing on failure.
+	var hops []*ssh.Client
+	closeHops := func() {
+		for i := len(hops) - 1; i >= 0; i-- {
+			_ = hops[i].Close()
+		}
+	}
+
+	for i, hop := range s.jumpHosts {
+		next := s.addr
+		if i+1 < len(s.jumpHosts) {
+			next = s.jumpHosts[i+1].Addr
+		}
+
+		client, err := s.dialJumpHost(ctx, nc, hop)
+		if err != nil {
+			closeHops()
+			return nil, err
+		}
+		hops = append(hops, client)
+
+		nc, err = dialTimeout(ctx, client, next, s.dialTimeout)
+		if err != nil {
+			closeHops()
+			return nil, fmt.Errorf("failed to dial %s through jump host %s: %w", next, hop.Addr, err)
+		}
+	}
+
+	if len(hops) == 0 {
+		return nc, nil
+	}
+	return &tunnelConn{Conn: nc, hops: hops}, nil
+}
+
+// dialJumpHost opens an SSH connection to hop over nc, with the client's
+// algorithm restrictions, giving up on the handshake after the client's
+// handshake timeout.
+func (s *OCEOSFTPClient) dialJumpHost(ctx context.Context, nc net.Conn, hop JumpHost) (*ssh.Client, error) {
+	stop := context.AfterFunc(ctx, func() {
+		_ = nc.Close()
+	})
+	wd := startWatchdog("SSH handshake with jump host "+hop.Addr, s.handshakeTimeout, func(error) {
+		_ = nc.Close()
+	})
+
+	config := &ssh.ClientConfig{
+		Config: ssh.Config{
+			KeyExchanges: s.config.KeyExchanges,
+			Ciphers:      s.config.Ciphers,
+			MACs:         s.config.MACs,
+		},
+		User:              hop.User,
+		Auth:              hop.Auth,
+		HostKeyCallback:   knownHostsCallback(hop.HostKeyCallback),
+		HostKeyAlgorithms: s.config.HostKeyAlgorithms,
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(nc, hop.Addr, config)
+	if err != nil {
+		stop()
+		wd.stop()
+		return nil, contextError(ctx, fmt.Errorf("failed to connect to jump host %s: %w", hop.Addr, wd.check(err)))
+	}
+	client := ssh.NewClient(c, chans, reqs)
+
+	if !stop() {
+		wd.stop()
+		_ = client.Close()
+		return nil, contextError(ctx, fmt.Errorf("failed to connect to jump host %s", hop.Addr))
+	}
+
+	if !wd.stop() {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to jump host %s: %w", hop.Addr, wd.check(nil))
+	}
+
+	return client, nil
+}
+
+// tunnelConn is a connection through jump hosts. Closing it closes the jump
+// host connections, last hop first.
+type tunnelConn struct {
+	net.Conn
+	hops []*ssh.Client
+}
+
+func (c *tunnelConn) Close() error {
+	err := c.Conn.Close()
+	for i := len(c.hops) - 1; i >= 0; i-- {
+		_ = c.hops[i].Close()
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package sftpclient
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// jumpHost returns a JumpHost for ts that authenticates with ts.key and
+// verifies its host key with hostKeyCallback.
+func (ts *testServer) jumpHost(t *testing.T, hostKeyCallback ssh.HostKeyCallback) JumpHost {
+	t.Helper()
+
+	signer, err := ssh.ParsePrivateKey(ts.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return JumpHost{
+		Addr:            ts.addr,
+		User:            "test",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+	}
+}
+
+func TestJumpHost(t *testing.T) {
+	jump := newTestServer(t)
+	ts := newTestServer(t)
+
+	c := ts.client(t, WithJumpHost(jump.jumpHost(t, ssh.FixedHostKey(jump.hostKey))))
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(filepath.Join(ts.root, "data"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("found files %v (%v) on the SFTP server, want one", files, err)
+	}
+}
+
+func TestJumpHostAlgorithms(t *testing.T) {
+	// The jump host only offers a cipher the client is not allowed to use.
+	jump := newTestServer(t, func(config *ssh.ServerConfig) {
+		config.Ciphers = []string{"chacha20-poly1305@openssh.com"}
+	})
+	ts := newTestServer(t)
+
+	hop := jump.jumpHost(t, ssh.FixedHostKey(jump.hostKey))
+	c := ts.client(t, WithJumpHost(hop), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatalf("upload without algorithm restrictions failed: %v", err)
+	}
+
+	c = ts.client(t, WithJumpHost(hop), WithCiphers("aes256-gcm@openssh.com"),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	err := Upload(context.Background(), c, "org", testCrew(1))
+	if err == nil || !strings.Contains(err.Error(), "jump host") {
+		t.Fatalf("upload returned %v, want the jump host handshake to fail", err)
+	}
+}
+
+func TestJumpHostKeyMismatch(t *testing.T) {
+	jump := newTestServer(t)
+	ts := newTestServer(t)
+
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := ssh.NewPublicKey(otherPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(jump.addr)}, otherKey)
+	if err := os.WriteFile(path, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cb, err := knownhosts.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := ts.client(t, WithJumpHost(jump.jumpHost(t, cb)), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	err = Upload(context.Background(), c, "org", testCrew(1))
+
+	var hkErr *HostKeyError
+	if !errors.As(err, &hkErr) {
+		t.Fatalf("upload returned %v, want a *HostKeyError", err)
+	}
+
+	if hkErr.Fingerprint != ssh.FingerprintSHA256(jump.hostKey) {
+		t.Errorf("HostKeyError fingerprint = %s, want the jump host's", hkErr.Fingerprint)
+	}
+
+	if len(hkErr.Want) != 1 || hkErr.Want[0] != ssh.FingerprintSHA256(otherKey) {
+		t.Errorf("HostKeyError want = %v, want the known_hosts key", hkErr.Want)
+	}
+}
@@ -0,0 +1,67 @@
+package sftpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// encode returns a reader over data as it is stored on the server. When
+// compression or encryption is enabled data is transformed as it is read.
+func (s *OCEOSFTPClient) encode(data []byte) io.ReadCloser {
+	if !s.gzip && s.pgp == nil {
+		return io.NopCloser(bytes.NewReader(data))
+	}
+
+	r, _ := s.encodeFunc(func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	return r
+}
+
+// encodeFunc returns a reader over the output of write as it is stored on
+// the server. write runs in its own goroutine as the reader is read, so the
+// output is never held in memory as a whole. Closing the reader makes
+// further writes fail. The returned channel is closed once the goroutine has
+// finished.
+func (s *OCEOSFTPClient) encodeFunc(write func(w io.Writer) error) (io.ReadCloser, <-chan struct{}) {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(s.writeEncoded(pw, write))
+	}()
+
+	return pr, done
+}
+
+// writeEncoded writes the output of write to w, compressing and then
+// encrypting it as configured.
+func (s *OCEOSFTPClient) writeEncoded(w io.Writer, write func(w io.Writer) error) error {
+	var closers []io.Closer
+	if s.pgp != nil {
+		ew, err := s.pgp.encrypt(w)
+		if err != nil {
+			return err
+		}
+		w = ew
+		closers = append(closers, ew)
+	}
+
+	if s.gzip {
+		// The level was validated by WithGzip.
+		zw, _ := gzip.NewWriterLevel(w, s.gzipLevel)
+		w = zw
+		closers = append(closers, zw)
+	}
+
+	err := write(w)
+	for i := len(closers) - 1; i >= 0; i-- {
+		if closeErr := closers[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
@@ -3,12 +3,14 @@ module github.com/Maritime-AI/oceo-sftp-csv-go
 go 1.21.4
 
 require (
+	github.com/ProtonMail/go-crypto v1.1.6
+	github.com/gocarina/gocsv v0.0.0-20240520201108-78e41c74b4b1
 	github.com/pkg/sftp v1.13.7
 	golang.org/x/crypto v0.31.0
 )
 
 require (
-	github.com/gocarina/gocsv v0.0.0-20240520201108-78e41c74b4b1 // indirect
+	github.com/cloudflare/circl v1.3.7 // indirect
 	github.com/kr/fs v0.1.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 )
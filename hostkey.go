@@ -0,0 +1,288 @@
+package sftpclient
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyError is returned when the host key presented by the SFTP server
+// cannot be verified against the configured known hosts, fingerprints or
+// trust-on-first-use store.
+type HostKeyError struct {
+	// Hostname is the address that was dialed.
+	Hostname string
+	// Fingerprint is the SHA256 fingerprint of the key the server presented.
+	Fingerprint string
+	// Want holds the SHA256 fingerprints that would have been accepted. It is
+	// empty when the host is unknown.
+	Want []string
+	// Revoked is set when the presented key is marked as revoked.
+	Revoked bool
+}
+
+func (e *HostKeyError) Error() string {
+	switch {
+	case e.Revoked:
+		return fmt.Sprintf("host key %s for %s is revoked", e.Fingerprint, e.Hostname)
+	case len(e.Want) == 0:
+		return fmt.Sprintf("host key %s for %s is unknown", e.Fingerprint, e.Hostname)
+	default:
+		return fmt.Sprintf("host key mismatch for %s: got %s, want %s",
+			e.Hostname, e.Fingerprint, strings.Join(e.Want, ", "))
+	}
+}
+
+// WithKnownHostsFile verifies the server host key against one or more
+// OpenSSH known_hosts files.
+//
+// Parameters:
+// - files: Paths to known_hosts files.
+//
+// Returns:
+// - An Option that sets the host key callback.
+func WithKnownHostsFile(files ...string) Option {
+	return func(s *OCEOSFTPClient) error {
+		if len(files) == 0 {
+			return errors.New("missing known hosts file")
+		}
+
+		cb, err := knownhosts.New(files...)
+		if err != nil {
+			return fmt.Errorf("failed to read known hosts: %w", err)
+		}
+
+		s.config.HostKeyCallback = knownHostsCallback(cb)
+		s.knownKeyTypes = func(hostname string) map[string]bool {
+			return knownKeyTypes(cb, hostname)
+		}
+		return nil
+	}
+}
+
+// WithHostKeyFingerprints verifies the server host key against a list of
+// pinned SHA256 fingerprints, as printed by `ssh-keygen -l`.
+//
+// Parameters:
+// - fingerprints: Accepted fingerprints, e.g. "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8".
+//
+// Returns:
+// - An Option that sets the host key callback.
+func WithHostKeyFingerprints(fingerprints ...string) Option {
+	return func(s *OCEOSFTPClient) error {
+		if len(fingerprints) == 0 {
+			return errors.New("missing host key fingerprints")
+		}
+
+		want := make([]string, 0, len(fingerprints))
+		for _, fp := range fingerprints {
+			fp = strings.TrimSpace(fp)
+			if !strings.HasPrefix(fp, "SHA256:") {
+				fp = "SHA256:" + fp
+			}
+			want = append(want, fp)
+		}
+
+		s.config.HostKeyCallback = func(hostname string, _ net.Addr, key ssh.PublicKey) error {
+			got := ssh.FingerprintSHA256(key)
+			for _, fp := range want {
+				if fp == got {
+					return nil
+				}
+			}
+
+			return &HostKeyError{
+				Hostname:    hostname,
+				Fingerprint: got,
+				Want:        want,
+			}
+		}
+		s.knownKeyTypes = nil
+		return nil
+	}
+}
+
+// WithTrustOnFirstUse records the server host key in a known_hosts file the
+// first time a host is seen and refuses to connect if it changes afterwards.
+//
+// Parameters:
+// - path: Path to the known_hosts file used as the trust store. It is created if missing.
+//
+// Returns:
+// - An Option that sets the host key callback.
+func WithTrustOnFirstUse(path string) Option {
+	return func(s *OCEOSFTPClient) error {
+		if len(path) == 0 {
+			return errors.New("missing trust on first use path")
+		}
+
+		store := &tofuStore{path: path}
+		s.config.HostKeyCallback = store.check
+		s.knownKeyTypes = store.knownKeyTypes
+		return nil
+	}
+}
+
+// WithInsecureIgnoreHostKey accepts any server host key. It leaves the
+// connection open to man-in-the-middle attacks and should only be used for
+// testing.
+//
+// Returns:
+// - An Option that disables host key verification.
+func WithInsecureIgnoreHostKey() Option {
+	return func(s *OCEOSFTPClient) error {
+		s.config.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+		s.knownKeyTypes = nil
+		return nil
+	}
+}
+
+// tofuStore is a known_hosts file that is appended to when an unknown host
+// is seen for the first time.
+type tofuStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (t *tofuStore) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open trust store: %w", err)
+	}
+	defer f.Close()
+
+	cb, err := knownhosts.New(t.path)
+	if err != nil {
+		return fmt.Errorf("failed to read trust store: %w", err)
+	}
+
+	err = knownHostsCallback(cb)(hostname, remote, key)
+
+	var hkErr *HostKeyError
+	if !errors.As(err, &hkErr) || hkErr.Revoked || len(hkErr.Want) > 0 {
+		return err
+	}
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		return fmt.Errorf("failed to record host key: %w", err)
+	}
+
+	return nil
+}
+
+// knownKeyTypes returns the types of the keys for hostname in the trust
+// store, or nil if the host is unknown or the store cannot be read.
+func (t *tofuStore) knownKeyTypes(hostname string) map[string]bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cb, err := knownhosts.New(t.path)
+	if err != nil {
+		return nil
+	}
+	return knownKeyTypes(cb, hostname)
+}
+
+// probeKey is a key no known_hosts file holds. Checking it makes a
+// knownhosts callback list the keys it knows for a host.
+var probeKey, _ = ssh.NewPublicKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public())
+
+// knownKeyTypes returns the types of the keys cb knows for hostname.
+func knownKeyTypes(cb ssh.HostKeyCallback, hostname string) map[string]bool {
+	// The address is only used if hostname is empty.
+	var keyErr *knownhosts.KeyError
+	if err := cb(hostname, &net.TCPAddr{}, probeKey); !errors.As(err, &keyErr) || len(keyErr.Want) == 0 {
+		return nil
+	}
+
+	types := make(map[string]bool, len(keyErr.Want))
+	for _, k := range keyErr.Want {
+		types[k.Key.Type()] = true
+	}
+	return types
+}
+
+// hostKeyAlgorithms returns the host key algorithms to offer the server. If
+// the host's key types are known, their algorithms are offered first, like
+// OpenSSH does, so that a server with several host keys presents one that
+// can be verified rather than the first of another type. The other
+// algorithms are still offered, so a changed key is reported as a
+// *HostKeyError.
+func (s *OCEOSFTPClient) hostKeyAlgorithms() []string {
+	configured := s.config.HostKeyAlgorithms
+	if s.knownKeyTypes == nil {
+		return configured
+	}
+
+	types := s.knownKeyTypes(s.addr)
+	if len(types) == 0 {
+		return configured
+	}
+
+	algorithms := configured
+	if algorithms == nil {
+		algorithms = supportedHostKeyAlgorithms
+	}
+
+	known := make([]string, 0, len(algorithms))
+	var others []string
+	for _, a := range algorithms {
+		if types[hostKeyType(a)] {
+			known = append(known, a)
+		} else {
+			others = append(others, a)
+		}
+	}
+	return append(known, others...)
+}
+
+// hostKeyType returns the type of the keys used with a host key algorithm.
+func hostKeyType(algorithm string) string {
+	switch algorithm {
+	case ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512:
+		return ssh.KeyAlgoRSA
+	default:
+		return algorithm
+	}
+}
+
+// knownHostsCallback converts knownhosts errors into HostKeyError.
+func knownHostsCallback(cb ssh.HostKeyCallback) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := cb(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		hkErr := &HostKeyError{
+			Hostname:    hostname,
+			Fingerprint: ssh.FingerprintSHA256(key),
+		}
+
+		var keyErr *knownhosts.KeyError
+		var revokedErr *knownhosts.RevokedError
+		switch {
+		case errors.As(err, &keyErr):
+			for _, k := range keyErr.Want {
+				hkErr.Want = append(hkErr.Want, ssh.FingerprintSHA256(k.Key))
+			}
+			return hkErr
+		case errors.As(err, &revokedErr):
+			hkErr.Revoked = true
+			return hkErr
+		default:
+			return err
+		}
+	}
+}
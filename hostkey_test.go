@@ -0,0 +1,171 @@
+package sftpclient
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// writeKnownHosts writes a known_hosts file with one line per key for addr
+// and returns its path. A marker such as "@revoked" may precede the keys.
+func writeKnownHosts(t *testing.T, marker, addr string, keys ...ssh.PublicKey) string {
+	t.Helper()
+
+	var b strings.Builder
+	for _, k := range keys {
+		if len(marker) > 0 {
+			b.WriteString(marker + " ")
+		}
+		b.WriteString(knownhosts.Line([]string{knownhosts.Normalize(addr)}, k) + "\n")
+	}
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// noRetry makes host key failures return at once.
+var noRetry = WithRetryPolicy(RetryPolicy{MaxAttempts: 1})
+
+func TestKnownHostsFile(t *testing.T) {
+	ts := newTestServer(t)
+	other := newHostKey(t)
+
+	c := ts.client(t, WithKnownHostsFile(writeKnownHosts(t, "", ts.addr, ts.hostKey)))
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatalf("upload with a known host key failed: %v", err)
+	}
+
+	c = ts.client(t, WithKnownHostsFile(writeKnownHosts(t, "", ts.addr, other)), noRetry)
+	err := Upload(context.Background(), c, "org", testCrew(1))
+
+	var hkErr *HostKeyError
+	if !errors.As(err, &hkErr) {
+		t.Fatalf("upload returned %v, want a *HostKeyError", err)
+	}
+
+	if hkErr.Fingerprint != ssh.FingerprintSHA256(ts.hostKey) ||
+		len(hkErr.Want) != 1 || hkErr.Want[0] != ssh.FingerprintSHA256(other) {
+		t.Errorf("HostKeyError = %+v, want the server's key and the known key", hkErr)
+	}
+
+	if IsRetryable(err) {
+		t.Error("host key mismatch is retryable")
+	}
+}
+
+func TestKnownHostsFileSeveralHostKeys(t *testing.T) {
+	// The server also has an ECDSA key, which the ssh package prefers, but
+	// only its Ed25519 key is known.
+	addECDSA, ecdsaKey := withECDSAHostKey(t)
+	ts := newTestServer(t, addECDSA)
+
+	for name, key := range map[string]ssh.PublicKey{"Ed25519": ts.hostKey, "ECDSA": ecdsaKey} {
+		var got string
+		c := ts.client(t, WithKnownHostsFile(writeKnownHosts(t, "", ts.addr, key)),
+			WithUploadCallback(func(r UploadResult) { got = r.Algorithms.HostKey }))
+		if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+			t.Errorf("%s known: %v", name, err)
+			continue
+		}
+
+		if got != key.Type() {
+			t.Errorf("%s known: negotiated host key %s, want %s", name, got, key.Type())
+		}
+	}
+}
+
+func TestKnownHostsFileRevoked(t *testing.T) {
+	ts := newTestServer(t)
+
+	c := ts.client(t, WithKnownHostsFile(writeKnownHosts(t, "@revoked", "*", ts.hostKey)), noRetry)
+	err := Upload(context.Background(), c, "org", testCrew(1))
+
+	var hkErr *HostKeyError
+	if !errors.As(err, &hkErr) || !hkErr.Revoked {
+		t.Fatalf("upload returned %v, want a revoked *HostKeyError", err)
+	}
+}
+
+func TestTrustOnFirstUse(t *testing.T) {
+	addECDSA, _ := withECDSAHostKey(t)
+	ts := newTestServer(t, addECDSA)
+	path := filepath.Join(t.TempDir(), "known_hosts")
+
+	c := ts.client(t, WithTrustOnFirstUse(path))
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatalf("first use failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], knownhosts.Normalize(ts.addr)+" ") {
+		t.Fatalf("trust store holds %q, want one line for %s", data, ts.addr)
+	}
+
+	// The recorded key is still used when another option changes the
+	// preferred host key algorithms.
+	c = ts.client(t, WithTrustOnFirstUse(path), WithHardenedAlgorithms(), noRetry)
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatalf("upload with the recorded key failed: %v", err)
+	}
+
+	// A different key for the recorded host is refused.
+	other := newTestServer(t)
+	line := strings.Replace(lines[0], knownhosts.Normalize(ts.addr), knownhosts.Normalize(other.addr), 1)
+	if err := os.WriteFile(path, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c = other.client(t, WithTrustOnFirstUse(path), noRetry)
+	err = Upload(context.Background(), c, "org", testCrew(1))
+
+	var hkErr *HostKeyError
+	if !errors.As(err, &hkErr) || len(hkErr.Want) != 1 {
+		t.Fatalf("upload with a changed key returned %v, want a *HostKeyError", err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != line+"\n" {
+		t.Errorf("refused key was recorded: %q", data)
+	}
+}
+
+func TestMissingHostKeyOption(t *testing.T) {
+	ts := newTestServer(t)
+	if _, err := NewOCEOSFTPCLient(ts.host, ts.port, "test", ts.key); err == nil {
+		t.Fatal("NewOCEOSFTPCLient succeeded without host key verification")
+	}
+
+	if _, err := NewOCEOSFTPCLient(ts.host, ts.port, "test", ts.key, WithInsecureIgnoreHostKey()); err != nil {
+		t.Fatalf("NewOCEOSFTPCLient with WithInsecureIgnoreHostKey failed: %v", err)
+	}
+}
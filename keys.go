@@ -0,0 +1,169 @@
+package sftpclient
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// ErrPassphraseRequired is returned when the private key is encrypted and
+	// no passphrase was given with WithPrivateKeyPassphrase.
+	ErrPassphraseRequired = errors.New("private key is encrypted and needs a passphrase")
+	// ErrIncorrectPassphrase is returned when the private key cannot be
+	// decrypted with the given passphrase.
+	ErrIncorrectPassphrase = errors.New("incorrect private key passphrase")
+)
+
+// WithPrivateKeyPassphrase decrypts the private key passed to
+// NewOCEOSFTPCLient with passphrase.
+//
+// Parameters:
+// - passphrase: The passphrase of the private key.
+//
+// Returns:
+// - An Option that sets the private key passphrase.
+func WithPrivateKeyPassphrase(passphrase []byte) Option {
+	return func(s *OCEOSFTPClient) error {
+		if len(passphrase) == 0 {
+			return errors.New("missing private key passphrase")
+		}
+
+		s.keyPassphrase = passphrase
+		return nil
+	}
+}
+
+// WithCertificate authenticates with an OpenSSH certificate for the private
+// key passed to NewOCEOSFTPCLient, such as a short-lived certificate issued
+// by an SSH CA.
+//
+// Parameters:
+// - certBytes: The certificate in authorized_keys format, as found in an
+// "id_ed25519-cert.pub" file.
+//
+// Returns:
+// - An Option that sets the certificate.
+func WithCertificate(certBytes []byte) Option {
+	return func(s *OCEOSFTPClient) error {
+		cert, err := parseCertificate(certBytes)
+		if err != nil {
+			return err
+		}
+
+		s.cert = cert
+		s.certFunc = nil
+		return nil
+	}
+}
+
+// WithCertificateFunc is like WithCertificate, but fn is called for the
+// certificate on every connection. This lets a long-lived client use
+// certificates that expire sooner than it does, by returning a freshly
+// issued certificate whenever the current one is about to expire.
+//
+// Parameters:
+// - fn: Returns the certificate in authorized_keys format. An error fails
+// the connection attempt.
+//
+// Returns:
+// - An Option that sets the certificate provider.
+func WithCertificateFunc(fn func() ([]byte, error)) Option {
+	return func(s *OCEOSFTPClient) error {
+		if fn == nil {
+			return errors.New("missing certificate func")
+		}
+
+		s.certFunc = fn
+		s.cert = nil
+		return nil
+	}
+}
+
+// parseCertificate parses an unexpired OpenSSH user certificate in
+// authorized_keys format.
+func parseCertificate(certBytes []byte) (*ssh.Certificate, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("not an SSH certificate")
+	}
+
+	if cert.CertType != ssh.UserCert {
+		return nil, errors.New("not an SSH user certificate")
+	}
+
+	if cert.ValidBefore != ssh.CertTimeInfinity &&
+		time.Now().After(time.Unix(int64(cert.ValidBefore), 0)) {
+		return nil, fmt.Errorf("certificate expired at %s",
+			time.Unix(int64(cert.ValidBefore), 0).UTC().Format(time.RFC3339))
+	}
+
+	return cert, nil
+}
+
+// readPrivateKey parses a private key of any type supported by the ssh
+// package, decrypting it with passphrase if one is given.
+func readPrivateKey(keyBytes, passphrase []byte) (ssh.Signer, error) {
+	var signer ssh.Signer
+	var err error
+	if len(passphrase) > 0 {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(keyBytes, passphrase)
+	} else {
+		signer, err = ssh.ParsePrivateKey(keyBytes)
+	}
+
+	var missingErr *ssh.PassphraseMissingError
+	switch {
+	case errors.As(err, &missingErr):
+		return nil, ErrPassphraseRequired
+	case errors.Is(err, x509.IncorrectPasswordError):
+		return nil, ErrIncorrectPassphrase
+	case err != nil:
+		return nil, err
+	}
+
+	return signer, nil
+}
+
+// publicKeyAuth returns the public key auth method for signer, paired with
+// the client's certificate if one is configured.
+func (s *OCEOSFTPClient) publicKeyAuth(signer ssh.Signer) (ssh.AuthMethod, error) {
+	if s.certFunc != nil {
+		certFunc := s.certFunc
+		return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			certBytes, err := certFunc()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get certificate: %w", err)
+			}
+
+			cert, err := parseCertificate(certBytes)
+			if err != nil {
+				return nil, err
+			}
+
+			certSigner, err := ssh.NewCertSigner(cert, signer)
+			if err != nil {
+				return nil, fmt.Errorf("failed to use certificate: %w", err)
+			}
+			return []ssh.Signer{certSigner}, nil
+		}), nil
+	}
+
+	if s.cert != nil {
+		certSigner, err := ssh.NewCertSigner(s.cert, signer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to use certificate: %w", err)
+		}
+		signer = certSigner
+	}
+
+	return ssh.PublicKeys(signer), nil
+}
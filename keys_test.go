@@ -0,0 +1,301 @@
+package sftpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// withUserKey makes the server accept pub instead of its own user key.
+func withUserKey(pub ssh.PublicKey) func(*ssh.ServerConfig) {
+	return func(config *ssh.ServerConfig) {
+		config.PublicKeyCallback = func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), pub.Marshal()) {
+				return nil, errUnknownKey
+			}
+			return nil, nil
+		}
+	}
+}
+
+// withUserCA makes the server accept only certificates signed by ca.
+func withUserCA(ca ssh.PublicKey) func(*ssh.ServerConfig) {
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), ca.Marshal())
+		},
+	}
+	return func(config *ssh.ServerConfig) {
+		config.PublicKeyCallback = checker.Authenticate
+	}
+}
+
+// newUserKey generates an Ed25519 key and returns it PEM encoded with its
+// public key.
+func newUserKey(t *testing.T) ([]byte, ssh.PublicKey) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(block), sshPub
+}
+
+// signUserCert returns a certificate for pub and the user "test", signed by
+// ca and valid until validBefore, in authorized_keys format.
+func signUserCert(t *testing.T, ca ssh.Signer, pub ssh.PublicKey, validBefore time.Time) []byte {
+	t.Helper()
+
+	cert := &ssh.Certificate{
+		Key:             pub,
+		CertType:        ssh.UserCert,
+		KeyId:           "test",
+		ValidPrincipals: []string{"test"},
+		ValidAfter:      uint64(time.Now().Add(-time.Minute).Unix()),
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+
+	return ssh.MarshalAuthorizedKey(cert)
+}
+
+// newCA generates an Ed25519 SSH certificate authority.
+func newCA(t *testing.T) ssh.Signer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestPrivateKeyTypes(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	openSSH := func(key crypto.PrivateKey) []byte {
+		block, err := ssh.MarshalPrivateKey(key, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(block)
+	}
+
+	pkcs8 := func(key crypto.PrivateKey) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+
+	ecDER, err := x509.MarshalECPrivateKey(ecdsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		pub  crypto.PublicKey
+		key  []byte
+	}{
+		{"rsa pkcs1", rsaKey.Public(), pem.EncodeToMemory(&pem.Block{
+			Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})},
+		{"ecdsa openssh", ecdsaKey.Public(), openSSH(ecdsaKey)},
+		{"ecdsa sec1", ecdsaKey.Public(), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER})},
+		{"ecdsa pkcs8", ecdsaKey.Public(), pkcs8(ecdsaKey)},
+		{"ed25519 openssh", ed25519Key.Public(), openSSH(ed25519Key)},
+		{"ed25519 pkcs8", ed25519Key.Public(), pkcs8(ed25519Key)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pub, err := ssh.NewPublicKey(tt.pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ts := newTestServer(t, withUserKey(pub))
+			c := ts.clientWithKey(t, tt.key)
+			if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestEncryptedPrivateKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := pem.EncodeToMemory(block)
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, withUserKey(sshPub))
+	c := ts.clientWithKey(t, key, WithPrivateKeyPassphrase([]byte("secret")))
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		want error
+	}{
+		{"no passphrase", nil, ErrPassphraseRequired},
+		{"wrong passphrase", []Option{WithPrivateKeyPassphrase([]byte("wrong"))}, ErrIncorrectPassphrase},
+	} {
+		opts := append([]Option{WithInsecureIgnoreHostKey()}, tt.opts...)
+		_, err := NewOCEOSFTPCLient(ts.host, ts.port, "test", key, opts...)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: NewOCEOSFTPCLient returned %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCertificateAuth(t *testing.T) {
+	ca := newCA(t)
+	key, pub := newUserKey(t)
+	ts := newTestServer(t, withUserCA(ca.PublicKey()))
+
+	cert := signUserCert(t, ca, pub, time.Now().Add(time.Hour))
+	c := ts.clientWithKey(t, key, WithCertificate(cert))
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatalf("upload with a certificate failed: %v", err)
+	}
+
+	c = ts.clientWithKey(t, key, noRetry)
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err == nil {
+		t.Fatal("upload without a certificate succeeded")
+	}
+
+	otherKey, otherPub := newUserKey(t)
+	otherCert := signUserCert(t, newCA(t), otherPub, time.Now().Add(time.Hour))
+	c = ts.clientWithKey(t, otherKey, WithCertificate(otherCert), noRetry)
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err == nil {
+		t.Fatal("upload with a certificate of an unknown CA succeeded")
+	}
+
+	for _, tt := range []struct {
+		name string
+		key  []byte
+		cert []byte
+		want string
+	}{
+		{"expired", key, signUserCert(t, ca, pub, time.Now().Add(-time.Second)), "certificate expired"},
+		{"other key", otherKey, cert, "failed to use certificate"},
+		{"public key", key, ssh.MarshalAuthorizedKey(pub), "not an SSH certificate"},
+		{"no private key", nil, cert, "certificate without private key"},
+	} {
+		_, err := NewOCEOSFTPCLient(ts.host, ts.port, "test", tt.key, WithInsecureIgnoreHostKey(),
+			WithPassword("unused"), WithCertificate(tt.cert))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: NewOCEOSFTPCLient returned %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCertificateFunc(t *testing.T) {
+	ca := newCA(t)
+	key, pub := newUserKey(t)
+	ts := newTestServer(t, withUserCA(ca.PublicKey()))
+
+	var calls atomic.Int32
+	c := ts.clientWithKey(t, key, WithCertificateFunc(func() ([]byte, error) {
+		calls.Add(1)
+		return signUserCert(t, ca, pub, time.Now().Add(time.Minute)), nil
+	}))
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := Upload(ctx, c, "org", testCrew(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("certificate func called %d times for one connection, want 1", got)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Upload(ctx, c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("certificate func called %d times for two connections, want 2", got)
+	}
+
+	for _, tt := range []struct {
+		name string
+		fn   func() ([]byte, error)
+		want string
+	}{
+		{"error", func() ([]byte, error) { return nil, errors.New("CA unavailable") }, "CA unavailable"},
+		{"expired", func() ([]byte, error) {
+			return signUserCert(t, ca, pub, time.Now().Add(-time.Second)), nil
+		}, "certificate expired"},
+	} {
+		c := ts.clientWithKey(t, key, WithCertificateFunc(tt.fn), noRetry)
+		if err := Upload(ctx, c, "org", testCrew(1)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Upload returned %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
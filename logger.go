@@ -0,0 +1,89 @@
+package sftpclient
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Keys of the attributes the client logs. Record contents are never logged.
+const (
+	logKeyOrg        = "org"
+	logKeyFileType   = "file_type"
+	logKeyRemotePath = "remote_path"
+	logKeyBytes      = "bytes"
+	logKeyRows       = "rows"
+	logKeyDuration   = "duration"
+	logKeyError      = "error"
+)
+
+// WithLogger logs what the client does to logger. Completed uploads are
+// logged at info level with the attributes "org", "file_type",
+// "remote_path", "bytes", "rows" and "duration", and problems the client
+// recovers from at warn level. Record contents are never logged. By default
+// the client logs nothing.
+//
+// Parameters:
+// - logger: The logger to log to.
+//
+// Returns:
+// - An Option that sets the logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *OCEOSFTPClient) error {
+		if logger == nil {
+			return errors.New("missing logger")
+		}
+
+		s.logger = logger
+		return nil
+	}
+}
+
+// WithLogHandler logs what the client does to h. See WithLogger.
+//
+// Parameters:
+// - h: The handler to log to.
+//
+// Returns:
+// - An Option that sets the logger.
+func WithLogHandler(h slog.Handler) Option {
+	return func(s *OCEOSFTPClient) error {
+		if h == nil {
+			return errors.New("missing log handler")
+		}
+
+		s.logger = slog.New(h)
+		return nil
+	}
+}
+
+// logUpload logs a file that has been written to the server.
+func (s *OCEOSFTPClient) logUpload(ctx context.Context, orgName string,
+	fileType FileType, dest string, rows int, up *uploaded, start time.Time) {
+	s.logger.LogAttrs(ctx, slog.LevelInfo, "uploaded file",
+		slog.String(logKeyOrg, orgName),
+		slog.String(logKeyFileType, string(fileType)),
+		slog.String(logKeyRemotePath, dest),
+		slog.Int64(logKeyBytes, up.size),
+		slog.Int(logKeyRows, rows),
+		slog.Duration(logKeyDuration, time.Since(start)),
+	)
+}
+
+// logNothingToUpload logs an upload that was skipped for lack of records.
+func (s *OCEOSFTPClient) logNothingToUpload(ctx context.Context, orgName string, fileType FileType) {
+	s.logger.LogAttrs(ctx, slog.LevelInfo, "no records to upload",
+		slog.String(logKeyOrg, orgName),
+		slog.String(logKeyFileType, string(fileType)),
+	)
+}
+
+// discardHandler drops every log record. It is the default handler, so the
+// client logs nothing unless a logger is configured.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
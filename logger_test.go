@@ -0,0 +1,223 @@
+package sftpclient
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Maritime-AI/oceo-sftp-csv-go/models"
+)
+
+// captureHandler records every log record at debug level and above.
+type captureHandler struct {
+	mu      *sync.Mutex
+	records *[]capturedRecord
+	attrs   []slog.Attr
+}
+
+// capturedRecord is a log record with its attributes resolved.
+type capturedRecord struct {
+	level   slog.Level
+	message string
+	attrs   map[string]slog.Value
+}
+
+func newCaptureHandler() *captureHandler {
+	return &captureHandler{mu: &sync.Mutex{}, records: &[]capturedRecord{}}
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	rec := capturedRecord{level: r.Level, message: r.Message, attrs: make(map[string]slog.Value)}
+	for _, a := range h.attrs {
+		rec.attrs[a.Key] = a.Value.Resolve()
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		rec.attrs[a.Key] = a.Value.Resolve()
+		return true
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.records = append(*h.records, rec)
+	return nil
+}
+
+func (h *captureHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &c
+}
+
+func (h *captureHandler) WithGroup(string) slog.Handler { return h }
+
+// captured returns the records logged so far.
+func (h *captureHandler) captured() []capturedRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]capturedRecord(nil), *h.records...)
+}
+
+// piiCrew returns a crew member whose fields are easy to spot in logs.
+func piiCrew() *models.Crew {
+	email := "zebulon.quixote@example.com"
+	phone := "+1-555-0199"
+	return &models.Crew{
+		ContextID:      "ctx-secret",
+		CrewExternalID: "crew-zq-4711",
+		FirstName:      "Zebulon",
+		LastName:       "Quixote",
+		Email:          &email,
+		Phone:          &phone,
+	}
+}
+
+func TestDefaultLoggerLogsNothing(t *testing.T) {
+	h := newCaptureHandler()
+	prev := slog.Default()
+	slog.SetDefault(slog.New(h))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ts := newTestServer(t)
+	c := ts.client(t)
+	ctx := context.Background()
+	if err := Upload(ctx, c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Upload[*models.Crew](ctx, c, "org"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := h.captured(); len(got) != 0 {
+		t.Errorf("default client logged %+v", got)
+	}
+
+	for _, level := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+		if c.logger.Enabled(ctx, level) {
+			t.Errorf("default logger is enabled at %s", level)
+		}
+	}
+}
+
+func TestLogUploads(t *testing.T) {
+	h := newCaptureHandler()
+	ts := newTestServer(t)
+	c := ts.client(t, WithLogHandler(h), WithFileNameFunc(fixedFileName))
+	ctx := context.Background()
+
+	crew := []*models.Crew{piiCrew(), testCrew(2), testCrew(3)}
+	csvData := crewCSV(t, 3)
+	for name, upload := range map[string]func() error{
+		"Upload":     func() error { return Upload(ctx, c, "org", crew...) },
+		"UploadIter": func() error { return UploadIter(ctx, c, "org", crewIter(3)) },
+		"UploadCSV": func() error {
+			return UploadCSV[*models.Crew](ctx, c, "org", bytes.NewReader(csvData))
+		},
+	} {
+		before := len(h.captured())
+		if err := upload(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(ts.root, "data", "org_crew.csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var uploads []capturedRecord
+		for _, r := range h.captured()[before:] {
+			if r.message == "uploaded file" {
+				uploads = append(uploads, r)
+			}
+		}
+		if len(uploads) != 1 {
+			t.Fatalf("%s logged %d uploads, want 1", name, len(uploads))
+		}
+
+		r := uploads[0]
+		if r.level != slog.LevelInfo {
+			t.Errorf("%s: upload logged at %s, want info", name, r.level)
+		}
+
+		for key, want := range map[string]string{
+			logKeyOrg:        "org",
+			logKeyFileType:   string(FileTypeCrew),
+			logKeyRemotePath: "data/org_crew.csv",
+		} {
+			if got := r.attrs[key]; got.Kind() != slog.KindString || got.String() != want {
+				t.Errorf("%s: %s = %v, want %q", name, key, got, want)
+			}
+		}
+
+		if got := r.attrs[logKeyBytes]; got.Kind() != slog.KindInt64 || got.Int64() != int64(len(data)) {
+			t.Errorf("%s: %s = %v, want %d", name, logKeyBytes, got, len(data))
+		}
+
+		if got := r.attrs[logKeyRows]; got.Kind() != slog.KindInt64 || got.Int64() != 3 {
+			t.Errorf("%s: %s = %v, want 3", name, logKeyRows, got)
+		}
+
+		if got := r.attrs[logKeyDuration]; got.Kind() != slog.KindDuration || got.Duration() <= 0 {
+			t.Errorf("%s: %s = %v, want a positive duration", name, logKeyDuration, got)
+		}
+	}
+
+	if err := Upload[*models.Crew](ctx, c, "org"); err != nil {
+		t.Fatal(err)
+	}
+
+	records := h.captured()
+	last := records[len(records)-1]
+	if last.message != "no records to upload" || last.attrs[logKeyFileType].String() != string(FileTypeCrew) {
+		t.Errorf("empty upload logged %+v", last)
+	}
+}
+
+func TestLogsHoldNoRecordFields(t *testing.T) {
+	h := newCaptureHandler()
+	ts := newTestServer(t)
+	ctx := context.Background()
+
+	// Splitting and the bundle manifest log extra uploads, and an invalid
+	// record fails validation.
+	c := ts.client(t, WithLogHandler(h), WithSplitFiles(1, 0))
+	if err := Upload(ctx, c, "org", piiCrew(), testCrew(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.UploadBundle(ctx, "org", &models.Dataset{Crew: []models.Crew{*piiCrew()}}); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := piiCrew()
+	invalid.LastName = ""
+	if err := Upload(ctx, c, "org", invalid); err == nil {
+		t.Fatal("Upload accepted an invalid record")
+	}
+
+	records := h.captured()
+	if len(records) == 0 {
+		t.Fatal("nothing was logged")
+	}
+
+	pii := piiCrew()
+	fields := []string{pii.ContextID, pii.CrewExternalID, pii.FirstName, pii.LastName, *pii.Email, *pii.Phone}
+	for _, r := range records {
+		text := r.message
+		for key, v := range r.attrs {
+			text += " " + key + "=" + v.String()
+		}
+
+		for _, f := range fields {
+			if strings.Contains(text, f) {
+				t.Errorf("log record %q holds the record field %q", text, f)
+			}
+		}
+	}
+}
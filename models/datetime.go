@@ -0,0 +1,188 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DateTimeFormat is the canonical format of date/time values in uploaded
+// files. Values are always written in UTC.
+const DateTimeFormat = time.RFC3339
+
+// dateTimeLayouts are the input layouts that are always accepted. They are
+// all year first, so a value can never be read with day and month swapped.
+// Layouts without a zone are interpreted in the decoding location.
+var dateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+var (
+	// MonthFirstLayouts accept US style dates such as "03/04/2024" for
+	// March 4th. See DateTimeDecoding.
+	MonthFirstLayouts = []string{"01/02/2006 15:04:05", "01/02/2006"}
+	// DayFirstLayouts accept dates such as "03/04/2024" for April 3rd. See
+	// DateTimeDecoding.
+	DayFirstLayouts = []string{"02/01/2006 15:04:05", "02/01/2006"}
+)
+
+// DateTimeDecoding controls how values without a time zone or in a
+// non-standard layout are decoded. Use its Parse method, or the
+// WithCSVDateTimeDecoding option of UploadCSV, to decode one source; use
+// SetDateTimeDecoding to change the default for the whole process.
+type DateTimeDecoding struct {
+	// Location is the time zone of values that do not include one. Defaults
+	// to UTC.
+	Location *time.Location
+	// Layouts are accepted in addition to the year first layouts, in order.
+	// Slash separated dates are ambiguous, so they are only accepted if
+	// either MonthFirstLayouts or DayFirstLayouts is added here.
+	Layouts []string
+}
+
+var dateTimeDecoding atomic.Pointer[DateTimeDecoding]
+
+// SetDateTimeDecoding sets how ParseDateTime and the CSV and JSON decoding of
+// DateTime values treat values without a time zone and which extra layouts
+// they accept.
+//
+// The setting is process-wide: it applies to every decoder, and changing it
+// while values are being decoded makes the result depend on timing. Call it
+// once at startup, and use DateTimeDecoding.Parse or per-call options where
+// sources need different settings.
+//
+// Parameters:
+// - d: The decoding settings.
+func SetDateTimeDecoding(d DateTimeDecoding) {
+	if d.Location == nil {
+		d.Location = time.UTC
+	}
+	d.Layouts = append([]string(nil), d.Layouts...)
+	dateTimeDecoding.Store(&d)
+}
+
+// Parse parses s in the year first layouts or d.Layouts. Values without a
+// time zone are interpreted in d.Location, UTC if it is nil.
+func (d DateTimeDecoding) Parse(s string) (DateTime, error) {
+	loc := d.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return parseDateTime(s, loc, d.Layouts)
+}
+
+// decoding returns the current decoding settings.
+func decoding() DateTimeDecoding {
+	if d := dateTimeDecoding.Load(); d != nil {
+		return *d
+	}
+	return DateTimeDecoding{Location: time.UTC}
+}
+
+// DateTime is a point in time that is marshalled in DateTimeFormat for both CSV
+// and JSON. The zero value marshals to an empty string.
+type DateTime struct {
+	time.Time
+}
+
+// NewDateTime returns a DateTime for t.
+func NewDateTime(t time.Time) DateTime {
+	return DateTime{Time: t}
+}
+
+// ParseDateTime parses s with the settings of SetDateTimeDecoding: the year
+// first layouts and any extra layouts, with values without a time zone in
+// UTC by default.
+func ParseDateTime(s string) (DateTime, error) {
+	return decoding().Parse(s)
+}
+
+// ParseDateTimeInLocation parses s in the year first layouts. Values without
+// a time zone are interpreted in loc. It does not depend on
+// SetDateTimeDecoding.
+func ParseDateTimeInLocation(s string, loc *time.Location) (DateTime, error) {
+	return parseDateTime(s, loc, nil)
+}
+
+func parseDateTime(s string, loc *time.Location, extra []string) (DateTime, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return DateTime{}, nil
+	}
+
+	for _, layouts := range [][]string{dateTimeLayouts, extra} {
+		for _, layout := range layouts {
+			if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+				return DateTime{Time: t}, nil
+			}
+		}
+	}
+
+	return DateTime{}, fmt.Errorf("invalid date/time %q", s)
+}
+
+// String returns the time in DateTimeFormat, or an empty string for the zero value.
+func (dt DateTime) String() string {
+	if dt.IsZero() {
+		return ""
+	}
+	return dt.UTC().Format(DateTimeFormat)
+}
+
+// MarshalText implements encoding.TextMarshaler. It is used for CSV.
+func (dt DateTime) MarshalText() ([]byte, error) {
+	return []byte(dt.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It is used for CSV.
+// Values are parsed as by ParseDateTime.
+func (dt *DateTime) UnmarshalText(text []byte) error {
+	parsed, err := ParseDateTime(string(text))
+	if err != nil {
+		return err
+	}
+
+	*dt = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (dt DateTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dt.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (dt *DateTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*dt = DateTime{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	return dt.UnmarshalText([]byte(s))
+}
+
+// validateServicePeriod checks that end is not before start.
+func validateServicePeriod(start, end *DateTime) error {
+	if start == nil || end == nil || start.IsZero() || end.IsZero() {
+		return nil
+	}
+
+	if end.Before(start.Time) {
+		return newFieldError("ServiceEndAt", "service end at is before service start at")
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gocarina/gocsv"
+)
+
+func TestParseDateTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2024-03-04T05:06:07+02:00", time.Date(2024, 3, 4, 3, 6, 7, 0, time.UTC)},
+		{"2024-03-04 05:06:07", time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)},
+		{"2024-03-04", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDateTime(tt.in)
+		if err != nil {
+			t.Errorf("ParseDateTime(%q) returned %v", tt.in, err)
+			continue
+		}
+
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseDateTime(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"03/04/2024", "next tuesday"} {
+		if _, err := ParseDateTime(in); err == nil {
+			t.Errorf("ParseDateTime(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestDateTimeDecoding(t *testing.T) {
+	t.Cleanup(func() { SetDateTimeDecoding(DateTimeDecoding{}) })
+
+	loc := time.FixedZone("UTC+10", 10*3600)
+	SetDateTimeDecoding(DateTimeDecoding{Location: loc, Layouts: DayFirstLayouts})
+
+	got, err := ParseDateTime("03/04/2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Date(2024, 4, 3, 0, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("ParseDateTime = %s, want %s", got, want)
+	}
+
+	type row struct {
+		At DateTime `csv:"At"`
+	}
+
+	var rows []*row
+	if err := gocsv.UnmarshalString("At\n2024-04-03 12:00:00\n", &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := rows[0].At.String(), "2024-04-03T02:00:00Z"; got != want {
+		t.Errorf("decoded CSV value = %s, want %s", got, want)
+	}
+
+	SetDateTimeDecoding(DateTimeDecoding{Layouts: MonthFirstLayouts})
+	got, err = ParseDateTime("03/04/2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("ParseDateTime = %s, want %s", got, want)
+	}
+}
+
+func TestDateTimeDecodingParse(t *testing.T) {
+	loc := time.FixedZone("UTC+10", 10*3600)
+	dayFirst := DateTimeDecoding{Location: loc, Layouts: DayFirstLayouts}
+	monthFirst := DateTimeDecoding{Layouts: MonthFirstLayouts}
+
+	got, err := dayFirst.Parse("03/04/2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Date(2024, 4, 3, 0, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("day first Parse = %s, want %s", got, want)
+	}
+
+	got, err = monthFirst.Parse("03/04/2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("month first Parse = %s, want %s", got, want)
+	}
+
+	// Neither the package-wide setting nor ParseDateTimeInLocation is
+	// affected by the decodings above.
+	if _, err := ParseDateTime("03/04/2024"); err == nil {
+		t.Error("ParseDateTime accepted a slash date by default")
+	}
+
+	if _, err := ParseDateTimeInLocation("03/04/2024", loc); err == nil {
+		t.Error("ParseDateTimeInLocation accepted a slash date")
+	}
+}
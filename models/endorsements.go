@@ -0,0 +1,114 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Endorsements is a list of endorsements. In CSV files it is written as a
+// single value separated by Delimiter; in JSON it is an array of strings.
+//
+// Entries are trimmed of surrounding whitespace, and empty and duplicate
+// entries are dropped when marshalling and unmarshalling. An entry that
+// contains Delimiter is rejected.
+type Endorsements []string
+
+// ParseEndorsements splits a Delimiter-separated list of endorsements.
+func ParseEndorsements(s string) Endorsements {
+	return Endorsements(strings.Split(s, Delimiter)).Normalize()
+}
+
+// Normalize returns the endorsements trimmed of surrounding whitespace, with
+// empty and duplicate entries removed. The order of first occurrence is kept.
+func (e Endorsements) Normalize() Endorsements {
+	if len(e) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]struct{}, len(e))
+	out := make(Endorsements, 0, len(e))
+	for _, v := range e {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// Validate checks that no endorsement contains Delimiter.
+func (e Endorsements) Validate() error {
+	for _, v := range e {
+		if strings.Contains(v, Delimiter) {
+			return newFieldError("Endorsements",
+				fmt.Sprintf("endorsement %q contains delimiter %q", v, Delimiter))
+		}
+	}
+	return nil
+}
+
+// String returns the normalized endorsements joined by Delimiter.
+func (e Endorsements) String() string {
+	return strings.Join(e.Normalize(), Delimiter)
+}
+
+// MarshalText implements encoding.TextMarshaler. It is used for CSV.
+func (e Endorsements) MarshalText() ([]byte, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It is used for CSV.
+func (e *Endorsements) UnmarshalText(text []byte) error {
+	*e = ParseEndorsements(string(text))
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e Endorsements) MarshalJSON() ([]byte, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	n := e.Normalize()
+	if n == nil {
+		n = Endorsements{}
+	}
+	return json.Marshal([]string(n))
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts an array of strings
+// or a single Delimiter-separated string.
+func (e *Endorsements) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*e = ParseEndorsements(s)
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("invalid endorsements: %w", err)
+	}
+
+	n := Endorsements(list).Normalize()
+	if err := n.Validate(); err != nil {
+		return err
+	}
+
+	*e = n
+	return nil
+}
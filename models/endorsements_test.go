@@ -0,0 +1,146 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gocarina/gocsv"
+)
+
+func TestEndorsementsNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Endorsements
+		want Endorsements
+	}{
+		{name: "nil", in: nil, want: nil},
+		{name: "blank", in: Endorsements{"", "  "}, want: nil},
+		{name: "trim", in: Endorsements{" STCW ", "\tGMDSS"}, want: Endorsements{"STCW", "GMDSS"}},
+		{name: "dedupe", in: Endorsements{"STCW", "GMDSS", " STCW", "GMDSS"}, want: Endorsements{"STCW", "GMDSS"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.Normalize(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Normalize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEndorsements(t *testing.T) {
+	got := ParseEndorsements(" STCW *|*GMDSS*|**|*STCW")
+	if want := (Endorsements{"STCW", "GMDSS"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseEndorsements() = %q, want %q", got, want)
+	}
+
+	if got := ParseEndorsements(""); got != nil {
+		t.Errorf("ParseEndorsements(\"\") = %q, want nil", got)
+	}
+}
+
+func TestEndorsementsRejectDelimiter(t *testing.T) {
+	e := Endorsements{"STCW", "A" + Delimiter + "B"}
+
+	var fe *FieldError
+	if err := e.Validate(); !errors.As(err, &fe) || fe.Field != "Endorsements" {
+		t.Errorf("Validate() = %v, want a FieldError on Endorsements", err)
+	}
+
+	if _, err := e.MarshalText(); err == nil {
+		t.Error("MarshalText accepted an endorsement containing the delimiter")
+	}
+
+	if _, err := json.Marshal(e); err == nil {
+		t.Error("json.Marshal accepted an endorsement containing the delimiter")
+	}
+
+	cc := CrewCredential{ContextID: "ctx", CrewExternalID: "crew-1", Title: "Master", Endorsements: e}
+	if err := cc.Validate(); err == nil {
+		t.Error("CrewCredential.Validate accepted an endorsement containing the delimiter")
+	}
+}
+
+func TestEndorsementsCSVRoundTrip(t *testing.T) {
+	in := []CrewCredential{
+		{ContextID: "ctx", CrewExternalID: "crew-1", Title: "Master", Endorsements: Endorsements{" STCW", "GMDSS", "STCW"}},
+		{ContextID: "ctx", CrewExternalID: "crew-2", Title: "Mate"},
+	}
+
+	out, err := gocsv.MarshalString(&in)
+	if err != nil {
+		t.Fatalf("MarshalString: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), out)
+	}
+	if !strings.Contains(lines[1], ",STCW*|*GMDSS,") {
+		t.Errorf("row 1 = %q, want the endorsements joined by the delimiter", lines[1])
+	}
+
+	var got []CrewCredential
+	if err := gocsv.UnmarshalString(out, &got); err != nil {
+		t.Fatalf("UnmarshalString: %v", err)
+	}
+
+	want := []Endorsements{{"STCW", "GMDSS"}, nil}
+	for i, w := range want {
+		if !reflect.DeepEqual(got[i].Endorsements, w) {
+			t.Errorf("row %d endorsements = %q, want %q", i, got[i].Endorsements, w)
+		}
+	}
+}
+
+func TestEndorsementsJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Endorsements
+	}{
+		{name: "array", in: `[" STCW", "GMDSS", "STCW", ""]`, want: Endorsements{"STCW", "GMDSS"}},
+		{name: "string", in: `"STCW *|* GMDSS*|*STCW"`, want: Endorsements{"STCW", "GMDSS"}},
+		{name: "empty array", in: `[]`, want: nil},
+		{name: "empty string", in: `""`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Endorsements
+			if err := json.Unmarshal([]byte(tt.in), &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var e Endorsements
+	if err := json.Unmarshal([]byte(`["A*|*B"]`), &e); err == nil {
+		t.Error("Unmarshal accepted an array entry containing the delimiter")
+	}
+	if err := json.Unmarshal([]byte(`42`), &e); err == nil {
+		t.Error("Unmarshal accepted a number")
+	}
+
+	out, err := json.Marshal(Endorsements{" STCW", "GMDSS", "STCW"})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if got, want := string(out), `["STCW","GMDSS"]`; got != want {
+		t.Errorf("Marshal() = %s, want %s", got, want)
+	}
+
+	out, err = json.Marshal(Endorsements(nil))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if got, want := string(out), `[]`; got != want {
+		t.Errorf("Marshal(nil) = %s, want %s", got, want)
+	}
+}
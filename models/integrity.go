@@ -0,0 +1,254 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Dataset holds the records of every file type of a full sync.
+type Dataset struct {
+	Crew                    []Crew
+	CrewCredentials         []CrewCredential
+	CrewSeatime             []CrewSeatime
+	Vessels                 []Vessel
+	VesselSchedules         []VesselSchedule
+	VesselSchedulePositions []VesselSchedulePosition
+	CrewSchedules           []CrewSchedule
+	CrewSchedulePositions   []CrewSchedulePosition
+}
+
+// IntegrityIssueKind classifies an IntegrityIssue.
+type IntegrityIssueKind string
+
+const (
+	// IssueDanglingReference means a row refers to a crew member or vessel
+	// that is not in the dataset.
+	IssueDanglingReference IntegrityIssueKind = "dangling reference"
+	// IssueOrphanedPosition means a schedule position has no matching schedule.
+	IssueOrphanedPosition IntegrityIssueKind = "orphaned position"
+	// IssueContextMismatch means a row refers to a record with a different context ID.
+	IssueContextMismatch IntegrityIssueKind = "context mismatch"
+)
+
+// IntegrityIssue describes a row whose references cannot be resolved within
+// a Dataset.
+type IntegrityIssue struct {
+	Kind     IntegrityIssueKind
+	FileType FileType
+	// Index is the zero-based position of the row in its slice of the Dataset.
+	Index int
+	// ExternalID is the external ID of the row, if it has one.
+	ExternalID string
+	// Field is the name of the struct field holding the reference.
+	Field string
+	// Reason describes the issue.
+	Reason string
+}
+
+func (i IntegrityIssue) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s row %d", i.FileType, i.Index)
+	if len(i.ExternalID) > 0 {
+		fmt.Fprintf(&b, " (external id %s)", i.ExternalID)
+	}
+	fmt.Fprintf(&b, ": %s: %s", i.Kind, i.Reason)
+	return b.String()
+}
+
+// IntegrityError holds every issue found by CheckIntegrity.
+type IntegrityError struct {
+	Issues []IntegrityIssue
+}
+
+func (e *IntegrityError) Error() string {
+	var b strings.Builder
+	b.WriteString("dataset integrity check failed: ")
+
+	for i, issue := range e.Issues {
+		if i == maxRowsInMessage {
+			fmt.Fprintf(&b, "; and %d more", len(e.Issues)-i)
+			break
+		}
+
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(issue.String())
+	}
+
+	return b.String()
+}
+
+// CheckIntegrity checks that the references between the files of ds resolve:
+// crew external IDs must refer to a Crew, vessel external IDs to a Vessel,
+// schedule positions to a schedule of the same vessel (and crew member), and
+// every referenced record must share the referencing row's context ID.
+//
+// Parameters:
+// - ds: The dataset to check.
+//
+// Returns:
+// - An *IntegrityError listing every issue, or nil if the dataset is consistent.
+func CheckIntegrity(ds *Dataset) error {
+	if ds == nil {
+		return nil
+	}
+
+	c := integrityChecker{
+		crew:            make(contextIndex[string], len(ds.Crew)),
+		vessels:         make(contextIndex[string], len(ds.Vessels)),
+		vesselSchedules: make(contextIndex[string], len(ds.VesselSchedules)),
+		crewSchedules:   make(contextIndex[[2]string], len(ds.CrewSchedules)),
+	}
+
+	for _, cr := range ds.Crew {
+		c.crew.add(cr.CrewExternalID, cr.ContextID)
+	}
+
+	for _, v := range ds.Vessels {
+		c.vessels.add(v.VesselExternalID, v.ContextID)
+	}
+
+	for _, vs := range ds.VesselSchedules {
+		c.vesselSchedules.add(vs.VesselExternalID, vs.ContextID)
+	}
+
+	for _, cs := range ds.CrewSchedules {
+		c.crewSchedules.add([2]string{cs.CrewExternalID, cs.VesselExternalID}, cs.ContextID)
+	}
+
+	for i, cc := range ds.CrewCredentials {
+		c.crewRef(FileTypeCrewCredentials, i, cc.CrewExternalID, cc.ContextID, cc.CrewExternalID)
+	}
+
+	for i, st := range ds.CrewSeatime {
+		c.crewRef(FileTypeCrewSeatime, i, st.CrewExternalID, st.ContextID, st.CrewExternalID)
+	}
+
+	for i, vs := range ds.VesselSchedules {
+		c.vesselRef(FileTypeVesselSchedules, i, vs.ExternalID, vs.ContextID, vs.VesselExternalID)
+	}
+
+	for i, vp := range ds.VesselSchedulePositions {
+		c.vesselRef(FileTypeVesselSchedulePositions, i, vp.ExternalID, vp.ContextID, vp.VesselExternalID)
+
+		ok, others := c.vesselSchedules.resolve(vp.VesselExternalID, vp.ContextID)
+		switch {
+		case ok:
+		case len(others) == 0:
+			c.add(IssueOrphanedPosition, FileTypeVesselSchedulePositions, i, vp.ExternalID, "VesselExternalID",
+				fmt.Sprintf("no vessel schedule for vessel %s", vp.VesselExternalID))
+		default:
+			c.add(IssueContextMismatch, FileTypeVesselSchedulePositions, i, vp.ExternalID, "ContextID",
+				fmt.Sprintf("vessel schedule for vessel %s has context id %s, want %s",
+					vp.VesselExternalID, strings.Join(others, ", "), vp.ContextID))
+		}
+	}
+
+	for i, cs := range ds.CrewSchedules {
+		c.crewRef(FileTypeCrewSchedules, i, cs.ExternalID, cs.ContextID, cs.CrewExternalID)
+		c.vesselRef(FileTypeCrewSchedules, i, cs.ExternalID, cs.ContextID, cs.VesselExternalID)
+	}
+
+	for i, csp := range ds.CrewSchedulePositions {
+		c.crewRef(FileTypeCrewSchedulePositions, i, csp.ExternalID, csp.ContextID, csp.CrewExternalID)
+		c.vesselRef(FileTypeCrewSchedulePositions, i, csp.ExternalID, csp.ContextID, csp.VesselExternalID)
+
+		ok, others := c.crewSchedules.resolve([2]string{csp.CrewExternalID, csp.VesselExternalID}, csp.ContextID)
+		switch {
+		case ok:
+		case len(others) == 0:
+			c.add(IssueOrphanedPosition, FileTypeCrewSchedulePositions, i, csp.ExternalID, "CrewExternalID",
+				fmt.Sprintf("no crew schedule for crew %s on vessel %s", csp.CrewExternalID, csp.VesselExternalID))
+		default:
+			c.add(IssueContextMismatch, FileTypeCrewSchedulePositions, i, csp.ExternalID, "ContextID",
+				fmt.Sprintf("crew schedule for crew %s on vessel %s has context id %s, want %s",
+					csp.CrewExternalID, csp.VesselExternalID, strings.Join(others, ", "), csp.ContextID))
+		}
+	}
+
+	if len(c.issues) == 0 {
+		return nil
+	}
+
+	return &IntegrityError{Issues: c.issues}
+}
+
+// integrityChecker indexes the records that can be referred to and collects
+// issues.
+type integrityChecker struct {
+	crew            contextIndex[string]
+	vessels         contextIndex[string]
+	vesselSchedules contextIndex[string]
+	crewSchedules   contextIndex[[2]string]
+	issues          []IntegrityIssue
+}
+
+// contextIndex maps the external ID of a record to the context IDs it occurs
+// in. The same external ID may be used in several contexts.
+type contextIndex[K comparable] map[K]map[string]struct{}
+
+func (ix contextIndex[K]) add(key K, contextID string) {
+	contexts := ix[key]
+	if contexts == nil {
+		contexts = make(map[string]struct{})
+		ix[key] = contexts
+	}
+	contexts[contextID] = struct{}{}
+}
+
+// resolve reports whether key occurs in contextID. If it does not, others
+// lists the sorted context IDs it occurs in instead, and is empty if key is
+// unknown.
+func (ix contextIndex[K]) resolve(key K, contextID string) (ok bool, others []string) {
+	contexts := ix[key]
+	if _, ok := contexts[contextID]; ok {
+		return true, nil
+	}
+
+	for ctxID := range contexts {
+		others = append(others, ctxID)
+	}
+	sort.Strings(others)
+	return false, others
+}
+
+func (c *integrityChecker) add(kind IntegrityIssueKind, ft FileType, index int, externalID, field, reason string) {
+	c.issues = append(c.issues, IntegrityIssue{
+		Kind:       kind,
+		FileType:   ft,
+		Index:      index,
+		ExternalID: externalID,
+		Field:      field,
+		Reason:     reason,
+	})
+}
+
+// crewRef checks that crewExternalID refers to a Crew in the same context.
+func (c *integrityChecker) crewRef(ft FileType, index int, externalID, contextID, crewExternalID string) {
+	ok, others := c.crew.resolve(crewExternalID, contextID)
+	switch {
+	case ok:
+	case len(others) == 0:
+		c.add(IssueDanglingReference, ft, index, externalID, "CrewExternalID",
+			fmt.Sprintf("unknown crew %s", crewExternalID))
+	default:
+		c.add(IssueContextMismatch, ft, index, externalID, "ContextID",
+			fmt.Sprintf("crew %s has context id %s, want %s", crewExternalID, strings.Join(others, ", "), contextID))
+	}
+}
+
+// vesselRef checks that vesselExternalID refers to a Vessel in the same context.
+func (c *integrityChecker) vesselRef(ft FileType, index int, externalID, contextID, vesselExternalID string) {
+	ok, others := c.vessels.resolve(vesselExternalID, contextID)
+	switch {
+	case ok:
+	case len(others) == 0:
+		c.add(IssueDanglingReference, ft, index, externalID, "VesselExternalID",
+			fmt.Sprintf("unknown vessel %s", vesselExternalID))
+	default:
+		c.add(IssueContextMismatch, ft, index, externalID, "ContextID",
+			fmt.Sprintf("vessel %s has context id %s, want %s", vesselExternalID, strings.Join(others, ", "), contextID))
+	}
+}
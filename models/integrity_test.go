@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckIntegrityContexts(t *testing.T) {
+	ds := &Dataset{
+		Crew: []Crew{
+			{ContextID: "A", CrewExternalID: "X"},
+			{ContextID: "B", CrewExternalID: "X"},
+			{ContextID: "B", CrewExternalID: "Y"},
+		},
+		CrewCredentials: []CrewCredential{
+			{ContextID: "A", CrewExternalID: "X"},
+			{ContextID: "B", CrewExternalID: "X"},
+			{ContextID: "A", CrewExternalID: "Y"},
+			{ContextID: "A", CrewExternalID: "Z"},
+		},
+		Vessels: []Vessel{
+			{ContextID: "A", VesselExternalID: "V"},
+			{ContextID: "B", VesselExternalID: "V"},
+		},
+		VesselSchedules: []VesselSchedule{
+			{ContextID: "A", ExternalID: "vs-a", VesselExternalID: "V"},
+			{ContextID: "B", ExternalID: "vs-b", VesselExternalID: "V"},
+		},
+		VesselSchedulePositions: []VesselSchedulePosition{
+			{ContextID: "A", ExternalID: "vp-a", VesselExternalID: "V"},
+			{ContextID: "B", ExternalID: "vp-b", VesselExternalID: "V"},
+		},
+	}
+
+	err := CheckIntegrity(ds)
+	var ie *IntegrityError
+	if !errors.As(err, &ie) {
+		t.Fatalf("CheckIntegrity returned %v, want an *IntegrityError", err)
+	}
+
+	want := []struct {
+		kind  IntegrityIssueKind
+		index int
+	}{
+		{IssueContextMismatch, 2},
+		{IssueDanglingReference, 3},
+	}
+	if len(ie.Issues) != len(want) {
+		t.Fatalf("CheckIntegrity found %d issues, want %d: %v", len(ie.Issues), len(want), err)
+	}
+
+	for i, w := range want {
+		got := ie.Issues[i]
+		if got.Kind != w.kind || got.FileType != FileTypeCrewCredentials || got.Index != w.index {
+			t.Errorf("issue %d = %s, want %s on %s row %d", i, got, w.kind, FileTypeCrewCredentials, w.index)
+		}
+	}
+
+	if got, want := ie.Issues[0].Reason, "crew Y has context id B, want A"; got != want {
+		t.Errorf("mismatch reason = %q, want %q", got, want)
+	}
+}
+
+func TestCheckIntegrityOrphanedPosition(t *testing.T) {
+	ds := &Dataset{
+		Crew:    []Crew{{ContextID: "A", CrewExternalID: "X"}},
+		Vessels: []Vessel{{ContextID: "A", VesselExternalID: "V"}},
+		CrewSchedules: []CrewSchedule{
+			{ContextID: "A", ExternalID: "cs", CrewExternalID: "X", VesselExternalID: "V"},
+		},
+		CrewSchedulePositions: []CrewSchedulePosition{
+			{ContextID: "A", ExternalID: "ok", CrewExternalID: "X", VesselExternalID: "V"},
+		},
+		VesselSchedulePositions: []VesselSchedulePosition{
+			{ContextID: "A", ExternalID: "orphan", VesselExternalID: "V"},
+		},
+	}
+
+	err := CheckIntegrity(ds)
+	var ie *IntegrityError
+	if !errors.As(err, &ie) {
+		t.Fatalf("CheckIntegrity returned %v, want an *IntegrityError", err)
+	}
+
+	if len(ie.Issues) != 1 || ie.Issues[0].Kind != IssueOrphanedPosition ||
+		ie.Issues[0].FileType != FileTypeVesselSchedulePositions {
+		t.Fatalf("CheckIntegrity returned %v, want one orphaned vessel schedule position", err)
+	}
+}
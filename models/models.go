@@ -33,19 +33,19 @@ func (c *Crew) Validate() error {
 	}
 
 	if len(c.ContextID) == 0 {
-		return errors.New("missing context ID")
+		return newFieldError("ContextID", "missing context ID")
 	}
 
 	if len(c.CrewExternalID) == 0 {
-		return errors.New("missing crew external ID")
+		return newFieldError("CrewExternalID", "missing crew external ID")
 	}
 
 	if len(c.FirstName) == 0 {
-		return errors.New("missing first name")
+		return newFieldError("FirstName", "missing first name")
 	}
 
 	if len(c.LastName) == 0 {
-		return errors.New("missing last name")
+		return newFieldError("LastName", "missing last name")
 	}
 
 	return nil
@@ -82,9 +82,9 @@ type CrewCredential struct {
 	Title          string  `csv:"Title" json:"title"`
 	Type           *string `csv:"Type" json:"type"`
 	//Endorsements is a list of endorsements, separated by *|* Delimiter.
-	Endorsements string  `csv:"Endorsements" json:"endorsements"`
-	IssuedAt     *string `csv:"Issued At" json:"issued_at"`
-	ExpiresAt    *string `csv:"Expires At" json:"expires_at"`
+	Endorsements Endorsements `csv:"Endorsements" json:"endorsements"`
+	IssuedAt     *string      `csv:"Issued At" json:"issued_at"`
+	ExpiresAt    *string      `csv:"Expires At" json:"expires_at"`
 }
 
 // Validate checks if the required fields of a CrewCredential are set.
@@ -94,20 +94,25 @@ func (cc *CrewCredential) Validate() error {
 	}
 
 	if len(cc.ContextID) == 0 {
-		return errors.New("missing context id")
+		return newFieldError("ContextID", "missing context id")
 	}
 
 	if len(cc.CrewExternalID) == 0 {
-		return errors.New("missing crew external id")
+		return newFieldError("CrewExternalID", "missing crew external id")
 	}
 
 	if len(cc.Title) == 0 {
-		return errors.New("missing title")
+		return newFieldError("Title", "missing title")
 	}
 
-	return nil
+	return cc.Endorsements.Validate()
 }
 
+// CrewSeatime represents a period of sea service of a crew member.
+//
+// In the uploaded CSV, times are written in DateTimeFormat in UTC, booleans as
+// "true" or "false", numbers in plain decimal notation and nil fields as
+// empty values.
 type CrewSeatime struct {
 	ContextID             string     `csv:"Context ID" json:"context_id"`
 	CrewExternalID        string     `csv:"Crew External ID" json:"crew_external_id"`
@@ -132,33 +137,51 @@ type CrewSeatime struct {
 	WaterWay              *string    `csv:"Water Way" json:"water_way"`
 }
 
-// Validate checks if the required fields of a CrewCredential are set.
+// Validate checks if the required fields of a CrewSeatime are set.
 func (st *CrewSeatime) Validate() error {
 	if st == nil {
-		return errors.New("missing crew credential")
+		return errors.New("missing crew seatime")
 	}
 
 	if len(st.ContextID) == 0 {
-		return errors.New("missing context id")
+		return newFieldError("ContextID", "missing context id")
 	}
 
 	if len(st.CrewExternalID) == 0 {
-		return errors.New("missing crew external id")
+		return newFieldError("CrewExternalID", "missing crew external id")
 	}
 
 	if len(st.VesselName) == 0 {
-		return errors.New("missing vessel name")
+		return newFieldError("VesselName", "missing vessel name")
 	}
 
 	isCrewOnAndOff := st.CrewedOn != nil && st.CrewedOff != nil
 	isNumDays := st.NumDays != nil && *st.NumDays > 0
 	if !isCrewOnAndOff && !isNumDays {
-		return errors.New("must provide either crew on/off or num days")
+		return newFieldError("NumDays", "must provide either crew on/off or num days")
 	}
 
 	return nil
 }
 
+// NormalizeCSV returns a copy of st with the crew on and off times in UTC and
+// truncated to whole seconds, so that they are written in DateTimeFormat.
+func (st *CrewSeatime) NormalizeCSV() Record {
+	c := *st
+	c.CrewedOn = utcSeconds(st.CrewedOn)
+	c.CrewedOff = utcSeconds(st.CrewedOff)
+	return &c
+}
+
+func utcSeconds(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	u := t.UTC().Truncate(time.Second)
+	return &u
+}
+
 // NumDaysWorked returns the number of days the mariner was at sea
 func (st *CrewSeatime) NumDaysWorked() float64 {
 
@@ -221,19 +244,19 @@ func (v *Vessel) Validate() error {
 	}
 
 	if len(v.ContextID) == 0 {
-		return errors.New("missing context id")
+		return newFieldError("ContextID", "missing context id")
 	}
 
 	if len(v.ExternalID) == 0 {
-		return errors.New("missing external id")
+		return newFieldError("ExternalID", "missing external id")
 	}
 
 	if len(v.VesselExternalID) == 0 {
-		return errors.New("missing vessel external id")
+		return newFieldError("VesselExternalID", "missing vessel external id")
 	}
 
 	if len(v.Name) == 0 {
-		return errors.New("missing vessel name")
+		return newFieldError("Name", "missing vessel name")
 	}
 
 	return nil
@@ -241,16 +264,16 @@ func (v *Vessel) Validate() error {
 
 // VesselSchedule represents the schedule of a vessel.
 type VesselSchedule struct {
-	ContextID        string  `csv:"Context ID"`
-	ExternalID       string  `csv:"External ID"`
-	VesselExternalID string  `csv:"Vessel External ID"`
-	VesselName       string  `csv:"Vessel Name"`
-	VesselIMONumber  *string `csv:"Vessel IMO Number"`
-	VesselMMSINumber *string `csv:"Vessel MMSI Number"`
-	Client           *string `csv:"Client"`
-	Description      *string `csv:"Description"`
-	ServiceStartAt   string  `csv:"Service Start At"`
-	ServiceEndAt     string  `csv:"Service End At"`
+	ContextID        string   `csv:"Context ID"`
+	ExternalID       string   `csv:"External ID"`
+	VesselExternalID string   `csv:"Vessel External ID"`
+	VesselName       string   `csv:"Vessel Name"`
+	VesselIMONumber  *string  `csv:"Vessel IMO Number"`
+	VesselMMSINumber *string  `csv:"Vessel MMSI Number"`
+	Client           *string  `csv:"Client"`
+	Description      *string  `csv:"Description"`
+	ServiceStartAt   DateTime `csv:"Service Start At"`
+	ServiceEndAt     DateTime `csv:"Service End At"`
 }
 
 // Validate checks if the required fields of a VesselSchedule are set.
@@ -260,30 +283,30 @@ func (vs *VesselSchedule) Validate() error {
 	}
 
 	if len(vs.ContextID) == 0 {
-		return errors.New("missing context id")
+		return newFieldError("ContextID", "missing context id")
 	}
 
 	if len(vs.ExternalID) == 0 {
-		return errors.New("missing external id")
+		return newFieldError("ExternalID", "missing external id")
 	}
 
 	if len(vs.VesselName) == 0 {
-		return errors.New("missing vessel name")
+		return newFieldError("VesselName", "missing vessel name")
 	}
 
 	if len(vs.VesselExternalID) == 0 {
-		return errors.New("missing vessel external id")
+		return newFieldError("VesselExternalID", "missing vessel external id")
 	}
 
-	if len(vs.ServiceStartAt) == 0 {
-		return errors.New("missing service start at")
+	if vs.ServiceStartAt.IsZero() {
+		return newFieldError("ServiceStartAt", "missing service start at")
 	}
 
-	if len(vs.ServiceEndAt) == 0 {
-		return errors.New("missing service ended at")
+	if vs.ServiceEndAt.IsZero() {
+		return newFieldError("ServiceEndAt", "missing service ended at")
 	}
 
-	return nil
+	return validateServicePeriod(&vs.ServiceStartAt, &vs.ServiceEndAt)
 }
 
 // VesselSchedulePosition represents the position of a vessel.
@@ -294,9 +317,9 @@ type VesselSchedulePosition struct {
 	Position         string `csv:"Position"`
 	CredentialTitle  string `csv:"Credential Title"`
 	//Endorsements is a list of endorsements, separated by *|*.
-	Endorsements   *string `csv:"Endorsements"`
-	ServiceStartAt *string `csv:"Service Start At"`
-	ServiceEndAt   *string `csv:"Service End At"`
+	Endorsements   Endorsements `csv:"Endorsements"`
+	ServiceStartAt *DateTime    `csv:"Service Start At"`
+	ServiceEndAt   *DateTime    `csv:"Service End At"`
 }
 
 // Validate checks if the required fields of a VesselSchedulePosition are set.
@@ -306,39 +329,43 @@ func (vp *VesselSchedulePosition) Validate() error {
 	}
 
 	if len(vp.ExternalID) == 0 {
-		return errors.New("missing external id")
+		return newFieldError("ExternalID", "missing external id")
 	}
 
 	if len(vp.ContextID) == 0 {
-		return errors.New("missing context id")
+		return newFieldError("ContextID", "missing context id")
 	}
 
 	if len(vp.VesselExternalID) == 0 {
-		return errors.New("missing vessel external id")
+		return newFieldError("VesselExternalID", "missing vessel external id")
 	}
 
 	if len(vp.Position) == 0 {
-		return errors.New("missing position")
+		return newFieldError("Position", "missing position")
 	}
 
 	if len(vp.CredentialTitle) == 0 {
-		return errors.New("missing position credential title")
+		return newFieldError("CredentialTitle", "missing position credential title")
 	}
 
-	return nil
+	if err := vp.Endorsements.Validate(); err != nil {
+		return err
+	}
+
+	return validateServicePeriod(vp.ServiceStartAt, vp.ServiceEndAt)
 }
 
 // CrewSchedule represents the schedule of a crew member.
 type CrewSchedule struct {
-	ContextID        string  `csv:"Context ID"`
-	ExternalID       string  `csv:"External ID"`
-	CrewExternalID   string  `csv:"Crew External ID"`
-	VesselExternalID string  `csv:"Vessel External ID"`
-	VesselName       string  `csv:"Vessel Name"`
-	VesselIMONumber  *string `csv:"Vessel IMO Number"`
-	VesselMMSINumber *string `csv:"Vessel MMSI Number"`
-	ServiceStartAt   string  `csv:"Service Start At"`
-	ServiceEndAt     string  `csv:"Service End At"`
+	ContextID        string   `csv:"Context ID"`
+	ExternalID       string   `csv:"External ID"`
+	CrewExternalID   string   `csv:"Crew External ID"`
+	VesselExternalID string   `csv:"Vessel External ID"`
+	VesselName       string   `csv:"Vessel Name"`
+	VesselIMONumber  *string  `csv:"Vessel IMO Number"`
+	VesselMMSINumber *string  `csv:"Vessel MMSI Number"`
+	ServiceStartAt   DateTime `csv:"Service Start At"`
+	ServiceEndAt     DateTime `csv:"Service End At"`
 }
 
 // Validate checks if the required fields of a CrewSchedule are set.
@@ -348,34 +375,34 @@ func (vs *CrewSchedule) Validate() error {
 	}
 
 	if len(vs.ContextID) == 0 {
-		return errors.New("missing context id")
+		return newFieldError("ContextID", "missing context id")
 	}
 
 	if len(vs.ExternalID) == 0 {
-		return errors.New("missing external id")
+		return newFieldError("ExternalID", "missing external id")
 	}
 
 	if len(vs.VesselExternalID) == 0 {
-		return errors.New("missing vessel external id")
+		return newFieldError("VesselExternalID", "missing vessel external id")
 	}
 
 	if len(vs.CrewExternalID) == 0 {
-		return errors.New("missing crew external id")
+		return newFieldError("CrewExternalID", "missing crew external id")
 	}
 
 	if len(vs.VesselName) == 0 {
-		return errors.New("missing vessel name")
+		return newFieldError("VesselName", "missing vessel name")
 	}
 
-	if len(vs.ServiceStartAt) == 0 {
-		return errors.New("missing service started at")
+	if vs.ServiceStartAt.IsZero() {
+		return newFieldError("ServiceStartAt", "missing service started at")
 	}
 
-	if len(vs.ServiceEndAt) == 0 {
-		return errors.New("missing service ended at")
+	if vs.ServiceEndAt.IsZero() {
+		return newFieldError("ServiceEndAt", "missing service ended at")
 	}
 
-	return nil
+	return validateServicePeriod(&vs.ServiceStartAt, &vs.ServiceEndAt)
 }
 
 // CrewSchedulePosition represents the position details of a crew member in a schedule.
@@ -388,9 +415,9 @@ type CrewSchedulePosition struct {
 	CredentialTitle  string  `csv:"Credential Title"`
 	Status           *string `csv:"Status"`
 	//Endorsements is a list of endorsements, separated by *|* Delimiter.
-	Endorsements   string  `csv:"Endorsements"`
-	ServiceStartAt *string `csv:"Service Start At"`
-	ServiceEndAt   *string `csv:"Service End At"`
+	Endorsements   Endorsements `csv:"Endorsements"`
+	ServiceStartAt *DateTime    `csv:"Service Start At"`
+	ServiceEndAt   *DateTime    `csv:"Service End At"`
 }
 
 // Validate checks if the required fields of a CrewSchedulePosition are set.
@@ -400,28 +427,32 @@ func (vs *CrewSchedulePosition) Validate() error {
 	}
 
 	if len(vs.ContextID) == 0 {
-		return errors.New("missing context id")
+		return newFieldError("ContextID", "missing context id")
 	}
 
 	if len(vs.ExternalID) == 0 {
-		return errors.New("missing external id")
+		return newFieldError("ExternalID", "missing external id")
 	}
 
 	if len(vs.VesselExternalID) == 0 {
-		return errors.New("missing vessel external id")
+		return newFieldError("VesselExternalID", "missing vessel external id")
 	}
 
 	if len(vs.CrewExternalID) == 0 {
-		return errors.New("missing crew external id")
+		return newFieldError("CrewExternalID", "missing crew external id")
 	}
 
 	if len(vs.Position) == 0 {
-		return errors.New("missing position")
+		return newFieldError("Position", "missing position")
 	}
 
 	if len(vs.CredentialTitle) == 0 {
-		return errors.New("missing credential")
+		return newFieldError("CredentialTitle", "missing credential")
 	}
 
-	return nil
+	if err := vs.Endorsements.Validate(); err != nil {
+		return err
+	}
+
+	return validateServicePeriod(vs.ServiceStartAt, vs.ServiceEndAt)
 }
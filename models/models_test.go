@@ -0,0 +1,96 @@
+package models
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gocarina/gocsv"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestCrewSeatimeCSV(t *testing.T) {
+	on := time.Date(2024, 1, 2, 3, 4, 5, 999, time.FixedZone("CET", 3600))
+	off := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	isOn := true
+	numDays := 32.5
+	position := "Master"
+	shift := int64(12)
+	flagState := "PA"
+	vesselType := "Tanker"
+	gt := int64(5000)
+	hp := 7500.5
+	propulsion := "Diesel"
+	imo := int64(9074729)
+	mmsi := int64(353136000)
+	tonnage := int64(8000)
+	compensation := "1000"
+	frequency := "Daily"
+	company := "Example Shipping"
+	waterWay := "Ocean"
+
+	records := []*CrewSeatime{
+		{
+			ContextID:             "ctx",
+			CrewExternalID:        "crew-1",
+			CrewedOn:              &on,
+			IsCrewedOn:            &isOn,
+			CrewedOff:             &off,
+			NumDays:               &numDays,
+			Position:              &position,
+			ShiftInHours:          &shift,
+			VesselName:            "Example",
+			VesselFlag:            &flagState,
+			VesselType:            &vesselType,
+			VesselCapacityGT:      &gt,
+			VesselHorsePower:      &hp,
+			VesselPropulsionType:  &propulsion,
+			VesselIMONumber:       &imo,
+			VesselMMSINumber:      &mmsi,
+			VesselTonnage:         &tonnage,
+			Compensation:          &compensation,
+			CompensationFrequency: &frequency,
+			CompanyName:           &company,
+			WaterWay:              &waterWay,
+		},
+		{
+			ContextID:      "ctx",
+			CrewExternalID: "crew-2",
+			NumDays:        &numDays,
+			VesselName:     "Example",
+		},
+	}
+
+	orig := records[0]
+	for i, r := range records {
+		records[i] = r.NormalizeCSV().(*CrewSeatime)
+	}
+
+	if orig.CrewedOn.Location().String() != "CET" || orig.CrewedOn.Nanosecond() != 999 {
+		t.Error("NormalizeCSV modified the record")
+	}
+
+	got, err := gocsv.MarshalBytes(&records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "crew_seatime.csv")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(want) {
+		t.Errorf("CrewSeatime CSV mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package models
+
+// FileType identifies the kind of records held in an uploaded file.
+type FileType string
+
+const (
+	FileTypeCrew                    FileType = "crew"
+	FileTypeCrewCredentials         FileType = "credentials"
+	FileTypeCrewSeatime             FileType = "seatime"
+	FileTypeVessels                 FileType = "vessels"
+	FileTypeVesselSchedules         FileType = "vesselschedules"
+	FileTypeVesselSchedulePositions FileType = "vesselschedulepositions"
+	FileTypeCrewSchedules           FileType = "crewschedules"
+	FileTypeCrewSchedulePositions   FileType = "crewschedulepositions"
+)
+
+// Record is a row that can be uploaded to OCEO. Pointers to every model in
+// this package implement it.
+type Record interface {
+	// Validate checks if the required fields of the record are set.
+	Validate() error
+	// FileType returns the type of file the record is uploaded in.
+	FileType() FileType
+}
+
+// CSVNormalizer is implemented by records that are adjusted before they are
+// written to CSV, e.g. to write times in the format the server expects.
+type CSVNormalizer interface {
+	// NormalizeCSV returns a copy of the record as it is written to CSV.
+	NormalizeCSV() Record
+}
+
+// FileType returns FileTypeCrew.
+func (*Crew) FileType() FileType { return FileTypeCrew }
+
+// FileType returns FileTypeCrewCredentials.
+func (*CrewCredential) FileType() FileType { return FileTypeCrewCredentials }
+
+// FileType returns FileTypeCrewSeatime.
+func (*CrewSeatime) FileType() FileType { return FileTypeCrewSeatime }
+
+// FileType returns FileTypeVessels.
+func (*Vessel) FileType() FileType { return FileTypeVessels }
+
+// FileType returns FileTypeVesselSchedules.
+func (*VesselSchedule) FileType() FileType { return FileTypeVesselSchedules }
+
+// FileType returns FileTypeVesselSchedulePositions.
+func (*VesselSchedulePosition) FileType() FileType { return FileTypeVesselSchedulePositions }
+
+// FileType returns FileTypeCrewSchedules.
+func (*CrewSchedule) FileType() FileType { return FileTypeCrewSchedules }
+
+// FileType returns FileTypeCrewSchedulePositions.
+func (*CrewSchedulePosition) FileType() FileType { return FileTypeCrewSchedulePositions }
+
+var (
+	_ Record = (*Crew)(nil)
+	_ Record = (*CrewCredential)(nil)
+	_ Record = (*CrewSeatime)(nil)
+	_ Record = (*Vessel)(nil)
+	_ Record = (*VesselSchedule)(nil)
+	_ Record = (*VesselSchedulePosition)(nil)
+	_ Record = (*CrewSchedule)(nil)
+	_ Record = (*CrewSchedulePosition)(nil)
+
+	_ CSVNormalizer = (*CrewSeatime)(nil)
+)
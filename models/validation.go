@@ -0,0 +1,203 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxRowsInMessage caps how many rows ValidationError.Error lists.
+const maxRowsInMessage = 10
+
+// FieldError is returned by Validate when a single field of a record is invalid.
+type FieldError struct {
+	// Field is the name of the struct field, e.g. "LastName".
+	Field string
+	// Reason describes what is wrong, e.g. "missing last name".
+	Reason string
+}
+
+func newFieldError(field, reason string) *FieldError {
+	return &FieldError{Field: field, Reason: reason}
+}
+
+func (e *FieldError) Error() string {
+	return e.Reason
+}
+
+// RowError describes an invalid row of a file.
+type RowError struct {
+	// Index is the zero-based position of the row in the uploaded records.
+	Index int
+	// ExternalID is the external ID of the row, if it has one.
+	ExternalID string
+	// Field is the name of the invalid struct field, if known.
+	Field string
+	// Reason describes what is wrong with the row.
+	Reason string
+}
+
+func (e RowError) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "row %d", e.Index)
+	if len(e.ExternalID) > 0 {
+		fmt.Fprintf(&b, " (external id %s)", e.ExternalID)
+	}
+	fmt.Fprintf(&b, ": %s", e.Reason)
+	return b.String()
+}
+
+// ValidationError holds every invalid row of a set of records.
+type ValidationError struct {
+	FileType FileType
+	Rows     []RowError
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid %s data: ", e.FileType)
+
+	for i, r := range e.Rows {
+		if i == maxRowsInMessage {
+			fmt.Fprintf(&b, "; and %d more", len(e.Rows)-i)
+			break
+		}
+
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(r.String())
+	}
+
+	return b.String()
+}
+
+// GetExternalID returns the external ID of the crew member.
+func (c *Crew) GetExternalID() string {
+	if c == nil {
+		return ""
+	}
+	return c.CrewExternalID
+}
+
+// GetExternalID returns the external ID of the crew member the credential belongs to.
+func (cc *CrewCredential) GetExternalID() string {
+	if cc == nil {
+		return ""
+	}
+	return cc.CrewExternalID
+}
+
+// GetExternalID returns the external ID of the crew member the seatime belongs to.
+func (st *CrewSeatime) GetExternalID() string {
+	if st == nil {
+		return ""
+	}
+	return st.CrewExternalID
+}
+
+// GetExternalID returns the external ID of the vessel.
+func (v *Vessel) GetExternalID() string {
+	if v == nil {
+		return ""
+	}
+	return v.ExternalID
+}
+
+// GetExternalID returns the external ID of the vessel schedule.
+func (vs *VesselSchedule) GetExternalID() string {
+	if vs == nil {
+		return ""
+	}
+	return vs.ExternalID
+}
+
+// GetExternalID returns the external ID of the vessel schedule position.
+func (vp *VesselSchedulePosition) GetExternalID() string {
+	if vp == nil {
+		return ""
+	}
+	return vp.ExternalID
+}
+
+// GetExternalID returns the external ID of the crew schedule.
+func (cs *CrewSchedule) GetExternalID() string {
+	if cs == nil {
+		return ""
+	}
+	return cs.ExternalID
+}
+
+// GetExternalID returns the external ID of the crew schedule position.
+func (csp *CrewSchedulePosition) GetExternalID() string {
+	if csp == nil {
+		return ""
+	}
+	return csp.ExternalID
+}
+
+// ValidateRecords validates every record and reports all invalid rows.
+//
+// Parameters:
+// - records: The records to validate.
+//
+// Returns:
+// - A *ValidationError listing every invalid row, or nil if all rows are valid.
+func ValidateRecords[T Record](records []T) error {
+	var rows []RowError
+	for i, r := range records {
+		if err := r.Validate(); err != nil {
+			rows = append(rows, newRowError(i, r, err))
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// The file type comes from a record rather than the zero value of T, which
+	// is nil if T is an interface type such as Record.
+	return &ValidationError{
+		FileType: records[0].FileType(),
+		Rows:     rows,
+	}
+}
+
+// ValidateRecord validates a single record, such as one row of a stream.
+//
+// Parameters:
+// - index: The zero-based position of the record, used in the error.
+// - record: The record to validate.
+//
+// Returns:
+// - A *ValidationError for the row, or nil if the record is valid.
+func ValidateRecord[T Record](index int, record T) error {
+	err := record.Validate()
+	if err == nil {
+		return nil
+	}
+
+	return &ValidationError{
+		FileType: record.FileType(),
+		Rows:     []RowError{newRowError(index, record, err)},
+	}
+}
+
+// newRowError describes the validation error err of record r at index.
+func newRowError(index int, r Record, err error) RowError {
+	row := RowError{
+		Index:  index,
+		Reason: err.Error(),
+	}
+
+	if id, ok := r.(interface{ GetExternalID() string }); ok {
+		row.ExternalID = id.GetExternalID()
+	}
+
+	var fieldErr *FieldError
+	if errors.As(err, &fieldErr) {
+		row.Field = fieldErr.Field
+	}
+
+	return row
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateRecordsInterfaceType(t *testing.T) {
+	records := []Record{
+		&Crew{ContextID: "ctx", CrewExternalID: "crew-1", FirstName: "First", LastName: "Last"},
+		&Crew{ContextID: "ctx", CrewExternalID: "crew-2"},
+	}
+
+	err := ValidateRecords(records)
+
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) {
+		t.Fatalf("ValidateRecords returned %v, want a *ValidationError", err)
+	}
+
+	if vErr.FileType != FileTypeCrew {
+		t.Errorf("FileType = %q, want %q", vErr.FileType, FileTypeCrew)
+	}
+
+	if len(vErr.Rows) != 1 || vErr.Rows[0].Index != 1 {
+		t.Errorf("Rows = %+v, want row 1 only", vErr.Rows)
+	}
+}
+
+func TestValidateServicePeriod(t *testing.T) {
+	start := NewDateTime(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC))
+	vs := &VesselSchedule{
+		ContextID:        "ctx",
+		ExternalID:       "vs-1",
+		VesselExternalID: "v-1",
+		VesselName:       "Example",
+		ServiceStartAt:   start,
+	}
+
+	for _, tt := range []struct {
+		name  string
+		end   DateTime
+		valid bool
+	}{
+		{"after start", NewDateTime(start.Add(time.Hour)), true},
+		{"same as start", start, true},
+		{"before start", NewDateTime(start.Add(-time.Second)), false},
+	} {
+		vs.ServiceEndAt = tt.end
+		err := vs.Validate()
+		if tt.valid {
+			if err != nil {
+				t.Errorf("%s: Validate returned %v", tt.name, err)
+			}
+			continue
+		}
+
+		var fErr *FieldError
+		if !errors.As(err, &fErr) || fErr.Field != "ServiceEndAt" {
+			t.Errorf("%s: Validate returned %v, want a ServiceEndAt *FieldError", tt.name, err)
+		}
+	}
+
+	// Optional periods are only checked when both ends are set.
+	end := NewDateTime(start.Add(-time.Hour))
+	if err := validateServicePeriod(&start, &end); err == nil {
+		t.Error("validateServicePeriod accepted an end before the start")
+	}
+
+	if err := validateServicePeriod(&start, nil); err != nil {
+		t.Errorf("validateServicePeriod without an end returned %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package sftpclient
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+)
+
+// FileNameFunc builds the name of a remote file from the organization name,
+// the file type and the time of the upload.
+type FileNameFunc func(orgName string, fileType FileType, t time.Time) string
+
+var (
+	// ErrRemoteFileExists is returned when WithNoOverwrite is set and the
+	// remote file already exists.
+	ErrRemoteFileExists = errors.New("remote file already exists")
+	// ErrNoOverwriteUnsupported is returned when WithNoOverwrite is set and
+	// the server cannot move a file into place without replacing an existing
+	// one, because it lacks the hardlink@openssh.com extension.
+	ErrNoOverwriteUnsupported = errors.New("server cannot upload without overwriting")
+)
+
+// DefaultFileName names files "<org>_<type>_<unix seconds>_<random>.csv". The
+// random suffix keeps files of the same org and type uploaded within the same
+// second from colliding.
+func DefaultFileName(orgName string, fileType FileType, t time.Time) string {
+	return fmt.Sprintf(fileTemplate, orgName, fileType, t.Unix(), randomSuffix())
+}
+
+// randomSuffix returns 12 random hex characters.
+func randomSuffix() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms; fall back to
+		// the clock so names stay unique in practice.
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithRemoteDir sets the directory on the SFTP server that files are uploaded to.
+//
+// Parameters:
+// - dir: The remote directory. Defaults to "./data".
+//
+// Returns:
+// - An Option that sets the remote directory.
+func WithRemoteDir(dir string) Option {
+	return func(s *OCEOSFTPClient) error {
+		if len(dir) == 0 {
+			return errors.New("missing remote dir")
+		}
+
+		s.remoteDir = dir
+		return nil
+	}
+}
+
+// WithFileTypeDir uploads files of the given type to a subdirectory of the
+// remote directory.
+//
+// Parameters:
+// - fileType: The file type.
+// - dir: The subdirectory, relative to the remote directory.
+//
+// Returns:
+// - An Option that sets the subdirectory for the file type.
+func WithFileTypeDir(fileType FileType, dir string) Option {
+	return func(s *OCEOSFTPClient) error {
+		if len(dir) == 0 {
+			return errors.New("missing file type dir")
+		}
+
+		if path.IsAbs(dir) {
+			return fmt.Errorf("file type dir %q must be relative", dir)
+		}
+
+		if s.fileTypeDirs == nil {
+			s.fileTypeDirs = make(map[FileType]string)
+		}
+		s.fileTypeDirs[fileType] = dir
+		return nil
+	}
+}
+
+// WithFileNameFunc replaces DefaultFileName for naming remote files.
+//
+// Parameters:
+// - fn: The function that names remote files.
+//
+// Returns:
+// - An Option that sets the file name function.
+func WithFileNameFunc(fn FileNameFunc) Option {
+	return func(s *OCEOSFTPClient) error {
+		if fn == nil {
+			return errors.New("missing file name func")
+		}
+
+		s.fileName = fn
+		return nil
+	}
+}
+
+// WithNoOverwrite makes uploads fail with ErrRemoteFileExists instead of
+// replacing a remote file that already has the same name. The file is hard
+// linked into place, which fails atomically if it exists, so the server must
+// support the hardlink@openssh.com extension, as OpenSSH does. Uploads to
+// other servers fail with ErrNoOverwriteUnsupported.
+//
+// Returns:
+// - An Option that disables overwriting remote files.
+func WithNoOverwrite() Option {
+	return func(s *OCEOSFTPClient) error {
+		s.noOverwrite = true
+		return nil
+	}
+}
+
+// relativePath returns the path, relative to the remote directory, of a file
+// of fileType uploaded by orgName at t.
+func (s *OCEOSFTPClient) relativePath(orgName string, fileType FileType, t time.Time) (string, error) {
+	name, err := s.baseName(orgName, fileType, t)
+	if err != nil {
+		return "", err
+	}
+	return s.filePath(fileType, name, 0), nil
+}
+
+// baseName returns the name given by the client's FileNameFunc.
+func (s *OCEOSFTPClient) baseName(orgName string, fileType FileType, t time.Time) (string, error) {
+	name := s.fileName(orgName, fileType, t)
+	if len(name) == 0 || path.Base(name) != name {
+		return "", fmt.Errorf("invalid remote file name %q", name)
+	}
+	return name, nil
+}
+
+// filePath returns the path, relative to the remote directory, of part of the
+// file named name. Part 0 means the file is not split.
+func (s *OCEOSFTPClient) filePath(fileType FileType, name string, part int) string {
+	if part > 0 {
+		name = partName(name, part)
+	}
+
+	if s.gzip {
+		name += gzipSuffix
+	}
+
+	if s.pgp != nil {
+		name += pgpSuffix
+	}
+
+	return path.Join(s.fileTypeDirs[fileType], name)
+}
+
+// remotePath returns the full remote path of a path relative to the remote directory.
+func (s *OCEOSFTPClient) remotePath(rel string) string {
+	return path.Join(s.remoteDir, rel)
+}
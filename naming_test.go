@@ -0,0 +1,197 @@
+package sftpclient
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Maritime-AI/oceo-sftp-csv-go/models"
+	"github.com/pkg/sftp"
+)
+
+// fixedFileName names every file the same, like a deterministic FileNameFunc.
+func fixedFileName(orgName string, fileType FileType, _ time.Time) string {
+	return orgName + "_" + string(fileType) + ".csv"
+}
+
+func TestNoOverwrite(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, WithNoOverwrite(), WithFileNameFunc(fixedFileName))
+	ctx := context.Background()
+
+	if err := Upload(ctx, c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(ts.root, "data", "org_crew.csv")
+	want, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Upload(ctx, c, "org", testCrew(2)); !errors.Is(err, ErrRemoteFileExists) {
+		t.Fatalf("second upload returned %v, want ErrRemoteFileExists", err)
+	}
+
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(want) {
+		t.Errorf("existing file was changed to %q, want %q", got, want)
+	}
+}
+
+func TestNoOverwriteConcurrent(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+
+	const writers = 8
+	errs := make([]error, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		c := ts.client(t, WithNoOverwrite(), WithFileNameFunc(fixedFileName))
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = Upload(ctx, c, "org", testCrew(i))
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrRemoteFileExists):
+			t.Errorf("upload returned %v, want nil or ErrRemoteFileExists", err)
+		}
+	}
+
+	if succeeded != 1 {
+		t.Errorf("%d uploads succeeded, want 1", succeeded)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(ts.root, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("remote dir holds %d files, want only the uploaded file", len(entries))
+	}
+}
+
+func TestNoOverwriteWithoutHardlink(t *testing.T) {
+	if err := sftp.SetSFTPExtensions("posix-rename@openssh.com", "statvfs@openssh.com"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = sftp.SetSFTPExtensions("hardlink@openssh.com", "posix-rename@openssh.com", "statvfs@openssh.com")
+	})
+
+	ts := newTestServer(t)
+	c := ts.client(t, WithNoOverwrite())
+
+	if err := Upload(context.Background(), c, "org", testCrew(1)); !errors.Is(err, ErrNoOverwriteUnsupported) {
+		t.Fatalf("upload returned %v, want ErrNoOverwriteUnsupported", err)
+	}
+}
+
+func TestRemoteLayout(t *testing.T) {
+	vessel := &models.Vessel{ContextID: "ctx", ExternalID: "v-1", VesselExternalID: "v-1", Name: "Example"}
+
+	for _, tt := range []struct {
+		name       string
+		opts       []Option
+		crewPath   string
+		vesselPath string
+	}{
+		{
+			name:       "default",
+			crewPath:   "data/org_crew.csv",
+			vesselPath: "data/org_vessels.csv",
+		},
+		{
+			name:       "remote dir",
+			opts:       []Option{WithRemoteDir("inbox/staging")},
+			crewPath:   "inbox/staging/org_crew.csv",
+			vesselPath: "inbox/staging/org_vessels.csv",
+		},
+		{
+			name:       "file type dir",
+			opts:       []Option{WithRemoteDir("./inbox"), WithFileTypeDir(FileTypeCrew, "crew/in")},
+			crewPath:   "inbox/crew/in/org_crew.csv",
+			vesselPath: "inbox/org_vessels.csv",
+		},
+	} {
+		ts := newTestServer(t)
+
+		var remote []string
+		opts := append([]Option{WithFileNameFunc(fixedFileName), WithUploadCallback(func(r UploadResult) {
+			remote = append(remote, r.RemotePath)
+		})}, tt.opts...)
+		c := ts.client(t, opts...)
+
+		ctx := context.Background()
+		if err := Upload(ctx, c, "org", testCrew(1)); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if err := Upload(ctx, c, "org", vessel); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		for _, p := range []string{tt.crewPath, tt.vesselPath} {
+			if _, err := os.Stat(filepath.Join(ts.root, filepath.FromSlash(p))); err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+		}
+
+		// The remote paths are clean, without the old ".//./data" prefix.
+		if want := []string{tt.crewPath, tt.vesselPath}; !reflect.DeepEqual(remote, want) {
+			t.Errorf("%s: uploaded to %q, want %q", tt.name, remote, want)
+		}
+	}
+}
+
+func TestAbsoluteRemoteDir(t *testing.T) {
+	ts := newTestServer(t)
+	dir := filepath.ToSlash(filepath.Join(ts.root, "abs", "inbox"))
+	c := ts.client(t, WithRemoteDir(dir), WithFileNameFunc(fixedFileName))
+
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(ts.root, "abs", "inbox", "org_crew.csv")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNamingOptionErrors(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"empty remote dir":       WithRemoteDir(""),
+		"empty file type dir":    WithFileTypeDir(FileTypeCrew, ""),
+		"absolute file type dir": WithFileTypeDir(FileTypeCrew, "/crew"),
+		"nil file name func":     WithFileNameFunc(nil),
+	} {
+		if _, err := NewOCEOSFTPCLient("localhost", "22", "test", nil,
+			WithInsecureIgnoreHostKey(), WithPassword("pw"), opt); err == nil {
+			t.Errorf("%s: NewOCEOSFTPCLient succeeded, want an error", name)
+		}
+	}
+
+	ts := newTestServer(t)
+	c := ts.client(t, WithFileNameFunc(func(string, FileType, time.Time) string { return "../escape.csv" }))
+	if err := Upload(context.Background(), c, "org", testCrew(1)); err == nil {
+		t.Error("upload with a file name containing a directory succeeded")
+	}
+}
@@ -0,0 +1,4 @@
+package sftpclient
+
+// Option configures an OCEOSFTPClient when it is created with NewOCEOSFTPCLient.
+type Option func(*OCEOSFTPClient) error
@@ -0,0 +1,157 @@
+package sftpclient
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+const (
+	// pgpSuffix is appended to the names of encrypted files.
+	pgpSuffix = ".pgp"
+)
+
+// PGPConfig configures OpenPGP encryption of uploaded files. Keys are given
+// ASCII armored, as exported by e.g. gpg --armor --export.
+type PGPConfig struct {
+	// Recipients are the armored public keys the files are encrypted to,
+	// typically OCEO's key. At least one is required.
+	Recipients [][]byte
+	// Signer, if set, is the armored private key that signs the files.
+	Signer []byte
+	// SignerPassphrase decrypts Signer if its private key is encrypted.
+	SignerPassphrase []byte
+	// Armor writes ASCII armored instead of binary OpenPGP messages.
+	Armor bool
+}
+
+// pgpEncrypter holds the parsed keys of a PGPConfig.
+type pgpEncrypter struct {
+	recipients openpgp.EntityList
+	signer     *openpgp.Entity
+	armor      bool
+	config     *packet.Config
+}
+
+// WithOpenPGP encrypts, and optionally signs, uploaded files with OpenPGP.
+// Encryption happens after compression. Encrypted files get a ".pgp"
+// suffix, e.g. "org_crew_1700000000_0a1b2c3d4e5f.csv.pgp".
+//
+// Files are encrypted with AES-256 and signed with SHA-256, unless a
+// recipient's key states preferences that rule these out.
+//
+// Parameters:
+// - cfg: The recipients, signer and output format.
+//
+// Returns:
+// - An Option that enables encryption.
+func WithOpenPGP(cfg PGPConfig) Option {
+	return func(s *OCEOSFTPClient) error {
+		if len(cfg.Recipients) == 0 {
+			return errors.New("missing OpenPGP recipients")
+		}
+
+		e := &pgpEncrypter{
+			armor: cfg.Armor,
+			config: &packet.Config{
+				DefaultCipher: packet.CipherAES256,
+				DefaultHash:   crypto.SHA256,
+				// Compression is left to WithGzip so the two are not stacked.
+				DefaultCompressionAlgo: packet.CompressionNone,
+			},
+		}
+
+		for i, key := range cfg.Recipients {
+			entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+			if err != nil {
+				return fmt.Errorf("failed to read OpenPGP recipient %d: %w", i, err)
+			}
+			e.recipients = append(e.recipients, entities...)
+		}
+
+		if len(cfg.Signer) > 0 {
+			signer, err := readSigner(cfg.Signer, cfg.SignerPassphrase)
+			if err != nil {
+				return err
+			}
+			e.signer = signer
+		}
+
+		s.pgp = e
+		return nil
+	}
+}
+
+// readSigner reads an armored private key and decrypts it with passphrase if
+// it is encrypted.
+func readSigner(key, passphrase []byte) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenPGP signer: %w", err)
+	}
+
+	if len(entities) != 1 {
+		return nil, fmt.Errorf("OpenPGP signer holds %d keys, want 1", len(entities))
+	}
+
+	signer := entities[0]
+	if signer.PrivateKey == nil {
+		return nil, errors.New("OpenPGP signer has no private key")
+	}
+
+	if signer.PrivateKey.Encrypted {
+		if len(passphrase) == 0 {
+			return nil, errors.New("OpenPGP signer private key is encrypted")
+		}
+
+		if err := signer.DecryptPrivateKeys(passphrase); err != nil {
+			return nil, fmt.Errorf("failed to decrypt OpenPGP signer: %w", err)
+		}
+	}
+
+	return signer, nil
+}
+
+// encrypt returns a writer that encrypts to w. Closing it finishes the
+// message but does not close w.
+func (e *pgpEncrypter) encrypt(w io.Writer) (io.WriteCloser, error) {
+	var armored io.WriteCloser
+	if e.armor {
+		var err error
+		armored, err = armor.Encode(w, "PGP MESSAGE", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start OpenPGP armor: %w", err)
+		}
+		w = armored
+	}
+
+	plaintext, err := openpgp.Encrypt(w, e.recipients, e.signer,
+		&openpgp.FileHints{IsBinary: true}, e.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start OpenPGP encryption: %w", err)
+	}
+
+	return &pgpWriter{WriteCloser: plaintext, armored: armored}, nil
+}
+
+// pgpWriter closes the armor encoder after the encrypted message.
+type pgpWriter struct {
+	io.WriteCloser
+	armored io.WriteCloser
+}
+
+func (w *pgpWriter) Close() error {
+	err := w.WriteCloser.Close()
+	if w.armored != nil {
+		if armorErr := w.armored.Close(); err == nil {
+			err = armorErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+package sftpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// newPGPKey generates a key pair and returns the entity with its armored
+// public and private keys. The private key is encrypted if passphrase is set.
+func newPGPKey(t *testing.T, name string, passphrase []byte) (*openpgp.Entity, []byte, []byte) {
+	t.Helper()
+
+	config := &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA}
+	e, err := openpgp.NewEntity(name, "", name+"@example.com", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	armored := func(blockType string, serialize func(io.Writer) error) []byte {
+		var buf bytes.Buffer
+		w, err := armor.Encode(&buf, blockType, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := serialize(w); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	public := armored(openpgp.PublicKeyType, e.Serialize)
+	if len(passphrase) > 0 {
+		if err := e.EncryptPrivateKeys(passphrase, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	private := armored(openpgp.PrivateKeyType, func(w io.Writer) error {
+		return e.SerializePrivateWithoutSigning(w, config)
+	})
+
+	return e, public, private
+}
+
+func TestOpenPGPUpload(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		armor      bool
+		passphrase []byte
+	}{
+		{name: "binary"},
+		{name: "armored", armor: true},
+		{name: "encrypted signer", passphrase: []byte("secret")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			recipient, recipientPub, _ := newPGPKey(t, "oceo", nil)
+			signer, signerPub, signerPriv := newPGPKey(t, "org", tt.passphrase)
+
+			ts := newTestServer(t)
+			c := ts.client(t, WithOpenPGP(PGPConfig{
+				Recipients:       [][]byte{recipientPub},
+				Signer:           signerPriv,
+				SignerPassphrase: tt.passphrase,
+				Armor:            tt.armor,
+			}))
+
+			if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+				t.Fatal(err)
+			}
+
+			files, err := filepath.Glob(filepath.Join(ts.root, "data", "*"+pgpSuffix))
+			if err != nil || len(files) != 1 {
+				t.Fatalf("found encrypted files %v (%v), want one", files, err)
+			}
+
+			data, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var r io.Reader = bytes.NewReader(data)
+			if tt.armor {
+				block, err := armor.Decode(r)
+				if err != nil {
+					t.Fatalf("failed to decode armor: %v", err)
+				}
+				r = block.Body
+			}
+
+			signerKeys, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(signerPub))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			keyring := append(openpgp.EntityList{recipient}, signerKeys...)
+			md, err := openpgp.ReadMessage(r, keyring, nil, nil)
+			if err != nil {
+				t.Fatalf("failed to decrypt: %v", err)
+			}
+
+			plaintext, err := io.ReadAll(md.UnverifiedBody)
+			if err != nil {
+				t.Fatalf("failed to read message: %v", err)
+			}
+
+			if !md.IsSigned || md.SignatureError != nil || md.Signature == nil {
+				t.Fatalf("signature not verified: signed %t, error %v", md.IsSigned, md.SignatureError)
+			}
+
+			if md.SignedByKeyId != signer.PrimaryKey.KeyId {
+				t.Errorf("signed by key %X, want %X", md.SignedByKeyId, signer.PrimaryKey.KeyId)
+			}
+
+			if md.Signature.Hash != crypto.SHA256 {
+				t.Errorf("signature hash = %v, want SHA-256", md.Signature.Hash)
+			}
+
+			if !strings.Contains(string(plaintext), "crew-1") {
+				t.Errorf("decrypted file %q does not hold the uploaded record", plaintext)
+			}
+		})
+	}
+}
+
+func TestOpenPGPConfigErrors(t *testing.T) {
+	_, recipientPub, _ := newPGPKey(t, "oceo", nil)
+	_, signerPub, _ := newPGPKey(t, "org", nil)
+	_, _, lockedPriv := newPGPKey(t, "locked", []byte("secret"))
+
+	for _, tt := range []struct {
+		name string
+		cfg  PGPConfig
+	}{
+		{"no recipients", PGPConfig{}},
+		{"invalid recipient", PGPConfig{Recipients: [][]byte{[]byte("not a key")}}},
+		{"public signer", PGPConfig{Recipients: [][]byte{recipientPub}, Signer: signerPub}},
+		{"locked signer", PGPConfig{Recipients: [][]byte{recipientPub}, Signer: lockedPriv}},
+		{"wrong passphrase", PGPConfig{Recipients: [][]byte{recipientPub}, Signer: lockedPriv,
+			SignerPassphrase: []byte("wrong")}},
+	} {
+		_, err := NewOCEOSFTPCLient("localhost", "22", "test", nil, WithInsecureIgnoreHostKey(), WithOpenPGP(tt.cfg))
+		if err == nil || !strings.Contains(err.Error(), "OpenPGP") {
+			t.Errorf("%s: NewOCEOSFTPCLient returned %v, want an OpenPGP error", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package sftpclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ProxyAuth holds the credentials for a proxy.
+type ProxyAuth struct {
+	User     string
+	Password string
+}
+
+// WithSOCKS5Proxy connects through a SOCKS5 proxy. The proxy resolves the
+// server's host name.
+//
+// Parameters:
+// - addr: The "host:port" of the proxy.
+// - auth: The username and password for the proxy, or nil if it needs none.
+//
+// Returns:
+// - An Option that sets the dialer to the SOCKS5 proxy.
+func WithSOCKS5Proxy(addr string, auth *ProxyAuth) Option {
+	return func(s *OCEOSFTPClient) error {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid proxy address %q: %w", addr, err)
+		}
+
+		if auth != nil && (len(auth.User) > 255 || len(auth.Password) > 255) {
+			return errors.New("SOCKS5 proxy credentials are longer than 255 bytes")
+		}
+
+		s.dialer = &proxyDialer{addr: addr, auth: auth, connect: socks5Connect}
+		return nil
+	}
+}
+
+// WithHTTPProxy connects through an HTTP proxy with the CONNECT method.
+//
+// Parameters:
+// - addr: The "host:port" of the proxy.
+// - auth: The username and password for basic authentication, or nil if the proxy needs none.
+//
+// Returns:
+// - An Option that sets the dialer to the HTTP proxy.
+func WithHTTPProxy(addr string, auth *ProxyAuth) Option {
+	return func(s *OCEOSFTPClient) error {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid proxy address %q: %w", addr, err)
+		}
+
+		s.dialer = &proxyDialer{addr: addr, auth: auth, connect: httpConnect}
+		return nil
+	}
+}
+
+// proxyDialer dials through a proxy. connect asks the proxy to open a
+// connection to addr over conn and returns the connection to use.
+type proxyDialer struct {
+	addr    string
+	auth    *ProxyAuth
+	connect func(conn net.Conn, addr string, auth *ProxyAuth) (net.Conn, error)
+}
+
+func (d *proxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var nd net.Dialer
+	conn, err := nd.DialContext(ctx, network, d.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial proxy: %w", err)
+	}
+
+	// Unblock the proxy handshake when ctx is done.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+
+	pc, err := d.connect(conn, addr, d.auth)
+	if !stop() {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		_ = conn.Close()
+		return nil, contextError(ctx, fmt.Errorf("failed to connect through proxy %s: %w", d.addr, err))
+	}
+
+	return pc, nil
+}
+
+// SOCKS5 protocol values, see RFC 1928 and RFC 1929.
+const (
+	socks5Version          = 5
+	socks5NoAuth           = 0
+	socks5UserPassAuth     = 2
+	socks5NoAcceptableAuth = 0xff
+	socks5CmdConnect       = 1
+	socks5IPv4             = 1
+	socks5DomainName       = 3
+	socks5IPv6             = 4
+)
+
+// socks5Connect asks a SOCKS5 proxy to connect to addr.
+func socks5Connect(conn net.Conn, addr string, auth *ProxyAuth) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	if len(host) > 255 {
+		return nil, fmt.Errorf("host name %q is too long", host)
+	}
+
+	method := byte(socks5NoAuth)
+	if auth != nil {
+		method = socks5UserPassAuth
+	}
+
+	if _, err := conn.Write([]byte{socks5Version, 1, method}); err != nil {
+		return nil, err
+	}
+
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return nil, err
+	}
+
+	if reply[0] != socks5Version {
+		return nil, fmt.Errorf("unexpected SOCKS version %d", reply[0])
+	}
+
+	switch reply[1] {
+	case method:
+	case socks5NoAcceptableAuth:
+		return nil, errors.New("SOCKS5 proxy rejected the authentication method")
+	default:
+		return nil, fmt.Errorf("SOCKS5 proxy chose unsupported authentication method %d", reply[1])
+	}
+
+	if auth != nil {
+		msg := []byte{1, byte(len(auth.User))}
+		msg = append(msg, auth.User...)
+		msg = append(msg, byte(len(auth.Password)))
+		msg = append(msg, auth.Password...)
+		if _, err := conn.Write(msg); err != nil {
+			return nil, err
+		}
+
+		if _, err := io.ReadFull(conn, reply[:]); err != nil {
+			return nil, err
+		}
+
+		if reply[1] != 0 {
+			return nil, errors.New("SOCKS5 proxy authentication failed")
+		}
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		req = append(req, socks5DomainName, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5IPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socks5IPv6)
+		req = append(req, ip...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return nil, err
+	}
+
+	if head[1] != 0 {
+		return nil, fmt.Errorf("SOCKS5 proxy failed to connect: %s", socks5Error(head[1]))
+	}
+
+	// Skip the bound address and port.
+	var skip int
+	switch head[3] {
+	case socks5IPv4:
+		skip = net.IPv4len + 2
+	case socks5IPv6:
+		skip = net.IPv6len + 2
+	case socks5DomainName:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return nil, err
+		}
+		skip = int(n[0]) + 2
+	default:
+		return nil, fmt.Errorf("unknown SOCKS5 address type %d", head[3])
+	}
+
+	if _, err := io.CopyN(io.Discard, conn, int64(skip)); err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// socks5Error describes a SOCKS5 reply code.
+func socks5Error(code byte) string {
+	switch code {
+	case 1:
+		return "general failure"
+	case 2:
+		return "connection not allowed by ruleset"
+	case 3:
+		return "network unreachable"
+	case 4:
+		return "host unreachable"
+	case 5:
+		return "connection refused"
+	case 6:
+		return "TTL expired"
+	case 7:
+		return "command not supported"
+	case 8:
+		return "address type not supported"
+	default:
+		return fmt.Sprintf("unknown error %d", code)
+	}
+}
+
+// httpConnect asks an HTTP proxy to connect to addr with the CONNECT method.
+func httpConnect(conn net.Conn, addr string, auth *ProxyAuth) (net.Conn, error) {
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if auth != nil {
+		creds := base64.StdEncoding.EncodeToString([]byte(auth.User + ":" + auth.Password))
+		req += "Proxy-Authorization: Basic " + creds + "\r\n"
+	}
+	req += "\r\n"
+
+	if _, err := io.WriteString(conn, req); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP proxy refused to connect: %s", resp.Status)
+	}
+
+	// The SSH server speaks first, so its greeting may already be buffered.
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose first bytes are read from a buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,485 @@
+package sftpclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testProxy is an in-process SOCKS5 or HTTP CONNECT proxy. Its settings must
+// be set before it is started.
+type testProxy struct {
+	addr string
+	// auth are the credentials the proxy requires, if set.
+	auth *ProxyAuth
+	// reply, if set, is the SOCKS5 error code sent instead of connecting.
+	reply byte
+	// boundType is the address type of the bound address in SOCKS5 replies.
+	boundType byte
+	// coalesce makes the HTTP proxy send the server's greeting in the same
+	// write as its response.
+	coalesce bool
+
+	mu      sync.Mutex
+	targets []string
+}
+
+// startSOCKS5 starts p as a SOCKS5 proxy.
+func (p *testProxy) startSOCKS5(t *testing.T) *testProxy {
+	t.Helper()
+	if p.boundType == 0 {
+		p.boundType = socks5IPv4
+	}
+	p.start(t, p.serveSOCKS5)
+	return p
+}
+
+// startHTTP starts p as an HTTP CONNECT proxy.
+func (p *testProxy) startHTTP(t *testing.T) *testProxy {
+	t.Helper()
+	p.start(t, p.serveHTTP)
+	return p
+}
+
+func (p *testProxy) start(t *testing.T, serve func(net.Conn)) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	p.addr = l.Addr().String()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+}
+
+// connected records a connection to target.
+func (p *testProxy) connected(target string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.targets = append(p.targets, target)
+}
+
+// connectedTo returns the targets connected to so far.
+func (p *testProxy) connectedTo() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+func (p *testProxy) serveSOCKS5(conn net.Conn) {
+	var head [2]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return
+	}
+
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+
+	want := byte(socks5NoAuth)
+	if p.auth != nil {
+		want = socks5UserPassAuth
+	}
+
+	if !strings.Contains(string(methods), string([]byte{want})) {
+		_, _ = conn.Write([]byte{socks5Version, socks5NoAcceptableAuth})
+		return
+	}
+
+	if _, err := conn.Write([]byte{socks5Version, want}); err != nil {
+		return
+	}
+
+	if p.auth != nil {
+		readField := func() string {
+			var n [1]byte
+			if _, err := io.ReadFull(conn, n[:]); err != nil {
+				return ""
+			}
+			b := make([]byte, n[0])
+			_, _ = io.ReadFull(conn, b)
+			return string(b)
+		}
+
+		var version [1]byte
+		if _, err := io.ReadFull(conn, version[:]); err != nil {
+			return
+		}
+
+		user, password := readField(), readField()
+		if user != p.auth.User || password != p.auth.Password {
+			_, _ = conn.Write([]byte{1, 1})
+			return
+		}
+
+		if _, err := conn.Write([]byte{1, 0}); err != nil {
+			return
+		}
+	}
+
+	var req [4]byte
+	if _, err := io.ReadFull(conn, req[:]); err != nil {
+		return
+	}
+
+	var host string
+	switch req[3] {
+	case socks5IPv4, socks5IPv6:
+		ip := make(net.IP, net.IPv4len)
+		if req[3] == socks5IPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = ip.String()
+	case socks5DomainName:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return
+		}
+		b := make([]byte, n[0])
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return
+		}
+		host = string(b)
+	default:
+		return
+	}
+
+	var port [2]byte
+	if _, err := io.ReadFull(conn, port[:]); err != nil {
+		return
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+	p.connected(target)
+
+	reply := func(code byte) []byte {
+		msg := []byte{socks5Version, code, 0, p.boundType}
+		switch p.boundType {
+		case socks5IPv4:
+			msg = append(msg, make([]byte, net.IPv4len)...)
+		case socks5IPv6:
+			msg = append(msg, make([]byte, net.IPv6len)...)
+		case socks5DomainName:
+			msg = append(msg, byte(len("proxy.example")))
+			msg = append(msg, "proxy.example"...)
+		}
+		return append(msg, 0, 0)
+	}
+
+	if p.reply != 0 {
+		_, _ = conn.Write(reply(p.reply))
+		return
+	}
+
+	targetConn, err := net.Dial("tcp", target)
+	if err != nil {
+		_, _ = conn.Write(reply(5))
+		return
+	}
+	defer targetConn.Close()
+
+	if _, err := conn.Write(reply(0)); err != nil {
+		return
+	}
+	relay(conn, conn, targetConn)
+}
+
+func (p *testProxy) serveHTTP(conn net.Conn) {
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return
+	}
+
+	if req.Method != http.MethodConnect {
+		_, _ = io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+		return
+	}
+
+	if p.auth != nil {
+		creds := base64.StdEncoding.EncodeToString([]byte(p.auth.User + ":" + p.auth.Password))
+		if req.Header.Get("Proxy-Authorization") != "Basic "+creds {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n"+
+				"Proxy-Authenticate: Basic realm=\"test\"\r\n\r\n")
+			return
+		}
+	}
+
+	p.connected(req.Host)
+	targetConn, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer targetConn.Close()
+
+	resp := "HTTP/1.1 200 Connection established\r\n\r\n"
+	if p.coalesce {
+		greeting, err := bufio.NewReader(targetConn).ReadString('\n')
+		if err != nil {
+			return
+		}
+		resp += greeting
+	}
+
+	if _, err := io.WriteString(conn, resp); err != nil {
+		return
+	}
+	relay(conn, br, targetConn)
+}
+
+// relay copies between the client conn, read through r, and target until
+// either side is done.
+func relay(conn net.Conn, r io.Reader, target net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(target, r)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, target)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// closedAddr returns an address on which nothing listens.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	return addr
+}
+
+// proxyClient returns a client for the server ts that connects to host and
+// port, e.g. through the proxy set by opts. It does not retry.
+func proxyClient(t *testing.T, ts *testServer, host, port string, opts ...Option) *OCEOSFTPClient {
+	t.Helper()
+
+	opts = append([]Option{noRetry, WithHostKeyFingerprints(ssh.FingerprintSHA256(ts.hostKey))}, opts...)
+	c, err := NewOCEOSFTPCLient(host, port, "test", ts.key, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	auth := &ProxyAuth{User: "proxy", Password: "secret"}
+	for _, tt := range []struct {
+		name  string
+		host  string
+		proxy *testProxy
+		auth  *ProxyAuth
+	}{
+		{name: "no auth", proxy: &testProxy{}},
+		{name: "auth", proxy: &testProxy{auth: auth}, auth: auth},
+		{name: "host name", host: "localhost", proxy: &testProxy{}},
+		{name: "bound ipv6", proxy: &testProxy{boundType: socks5IPv6}},
+		{name: "bound host name", proxy: &testProxy{boundType: socks5DomainName}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			p := tt.proxy.startSOCKS5(t)
+
+			host := ts.host
+			if tt.host != "" {
+				host = tt.host
+			}
+
+			c := proxyClient(t, ts, host, ts.port, WithSOCKS5Proxy(p.addr, tt.auth))
+			if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+				t.Fatal(err)
+			}
+
+			want := net.JoinHostPort(host, ts.port)
+			if got := p.connectedTo(); len(got) != 1 || got[0] != want {
+				t.Errorf("proxy connected to %q, want %q", got, want)
+			}
+
+			files, err := os.ReadDir(filepath.Join(ts.root, "data"))
+			if err != nil || len(files) != 1 {
+				t.Errorf("found files %v (%v) on the SFTP server, want one", files, err)
+			}
+		})
+	}
+}
+
+func TestSOCKS5ProxyErrors(t *testing.T) {
+	ts := newTestServer(t)
+	auth := &ProxyAuth{User: "proxy", Password: "secret"}
+
+	for _, tt := range []struct {
+		name   string
+		proxy  *testProxy
+		auth   *ProxyAuth
+		target string
+		want   string
+	}{
+		{"missing auth", &testProxy{auth: auth}, nil, ts.addr, "rejected the authentication method"},
+		{"wrong password", &testProxy{auth: auth}, &ProxyAuth{User: "proxy", Password: "wrong"}, ts.addr,
+			"authentication failed"},
+		{"refused target", &testProxy{}, nil, closedAddr(t), "connection refused"},
+		{"ruleset", &testProxy{reply: 2}, nil, ts.addr, "connection not allowed by ruleset"},
+		{"unknown error", &testProxy{reply: 42}, nil, ts.addr, "unknown error 42"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.proxy.startSOCKS5(t)
+			host, port, _ := net.SplitHostPort(tt.target)
+
+			c := proxyClient(t, ts, host, port, WithSOCKS5Proxy(p.addr, tt.auth))
+			err := Upload(context.Background(), c, "org", testCrew(1))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Upload returned %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPProxy(t *testing.T) {
+	auth := &ProxyAuth{User: "proxy", Password: "secret"}
+	for _, tt := range []struct {
+		name  string
+		proxy *testProxy
+		auth  *ProxyAuth
+	}{
+		{name: "no auth", proxy: &testProxy{}},
+		{name: "auth", proxy: &testProxy{auth: auth}, auth: auth},
+		{name: "buffered greeting", proxy: &testProxy{coalesce: true}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			p := tt.proxy.startHTTP(t)
+
+			c := proxyClient(t, ts, ts.host, ts.port, WithHTTPProxy(p.addr, tt.auth))
+			if err := Upload(context.Background(), c, "org", testCrew(1)); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := p.connectedTo(); len(got) != 1 || got[0] != ts.addr {
+				t.Errorf("proxy connected to %q, want %q", got, ts.addr)
+			}
+		})
+	}
+}
+
+func TestHTTPProxyBufferedGreeting(t *testing.T) {
+	ts := newTestServer(t)
+	p := (&testProxy{coalesce: true}).startHTTP(t)
+
+	conn, err := net.Dial("tcp", p.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	pc, err := httpConnect(conn, ts.addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := pc.(*bufferedConn); !ok {
+		t.Fatalf("httpConnect returned a %T, want the greeting kept in a *bufferedConn", pc)
+	}
+
+	greeting, err := bufio.NewReader(pc).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(greeting, "SSH-2.0-") {
+		t.Errorf("read %q after the proxy response, want the SSH greeting", greeting)
+	}
+}
+
+func TestHTTPProxyErrors(t *testing.T) {
+	ts := newTestServer(t)
+	auth := &ProxyAuth{User: "proxy", Password: "secret"}
+
+	for _, tt := range []struct {
+		name   string
+		proxy  *testProxy
+		auth   *ProxyAuth
+		target string
+		want   string
+	}{
+		{"missing auth", &testProxy{auth: auth}, nil, ts.addr, "407"},
+		{"wrong password", &testProxy{auth: auth}, &ProxyAuth{User: "proxy", Password: "wrong"}, ts.addr, "407"},
+		{"refused target", &testProxy{}, nil, closedAddr(t), "502"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.proxy.startHTTP(t)
+			host, port, _ := net.SplitHostPort(tt.target)
+
+			c := proxyClient(t, ts, host, port, WithHTTPProxy(p.addr, tt.auth))
+			err := Upload(context.Background(), c, "org", testCrew(1))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Upload returned %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyOptionErrors(t *testing.T) {
+	long := strings.Repeat("x", 256)
+	for name, opt := range map[string]Option{
+		"socks5 address":  WithSOCKS5Proxy("localhost", nil),
+		"socks5 user":     WithSOCKS5Proxy("localhost:1080", &ProxyAuth{User: long}),
+		"socks5 password": WithSOCKS5Proxy("localhost:1080", &ProxyAuth{User: "u", Password: long}),
+		"http address":    WithHTTPProxy("localhost", nil),
+	} {
+		_, err := NewOCEOSFTPCLient("localhost", "22", "test", nil, WithInsecureIgnoreHostKey(),
+			WithPassword("unused"), opt)
+		if err == nil || !strings.Contains(err.Error(), "proxy") {
+			t.Errorf("%s: NewOCEOSFTPCLient returned %v, want a proxy error", name, err)
+		}
+	}
+}
+
+func TestProxyUnreachable(t *testing.T) {
+	ts := newTestServer(t)
+	for name, opt := range map[string]Option{
+		"socks5": WithSOCKS5Proxy(closedAddr(t), nil),
+		"http":   WithHTTPProxy(closedAddr(t), nil),
+	} {
+		c := proxyClient(t, ts, ts.host, ts.port, opt)
+		err := Upload(context.Background(), c, "org", testCrew(1))
+		if err == nil || !strings.Contains(err.Error(), "failed to dial proxy") {
+			t.Errorf("%s: Upload returned %v, want a proxy dial error", name, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package sftpclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how uploads that fail with a transient error are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. It doubles on every
+	// further retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts. Zero means no cap.
+	MaxDelay time.Duration
+	// Jitter is the fraction, between 0 and 1, of each delay that is
+	// randomized to spread out retries from concurrent uploads.
+	Jitter float64
+	// Retryable decides whether an error is worth retrying. Defaults to IsRetryable.
+	Retryable func(err error) bool
+	// OnRetry, if set, is called before waiting for the next attempt.
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// WithRetryPolicy retries uploads that fail with a transient error.
+//
+// Parameters:
+// - policy: The retry policy.
+//
+// Returns:
+// - An Option that sets the retry policy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(s *OCEOSFTPClient) error {
+		if policy.BaseDelay < 0 || policy.MaxDelay < 0 {
+			return errors.New("invalid retry delay")
+		}
+
+		if policy.Jitter < 0 || policy.Jitter > 1 {
+			return errors.New("invalid retry jitter")
+		}
+
+		s.retry = policy
+		return nil
+	}
+}
+
+// IsRetryable reports whether err is a transient network failure, such as a
+// timeout, a reset or refused connection or a connection lost mid-transfer.
+// Other network errors, such as an unknown host name, are permanent.
+// Authentication, host key and validation failures are never retryable, nor
+// is a cancelled or expired context.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var hkErr *HostKeyError
+	if errors.As(err, &hkErr) {
+		return false
+	}
+
+	if strings.Contains(err.Error(), "ssh: unable to authenticate") {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		isConnectionLost(err)
+}
+
+// do runs fn until it succeeds, fails with an error that is not retryable,
+// the attempts run out or ctx is done.
+func (p RetryPolicy) do(ctx context.Context, fn func() error) error {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+
+		delay := p.delay(attempt)
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, delay, err)
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return contextError(ctx, err)
+		case <-t.C:
+		}
+	}
+}
+
+// delay returns the backoff before the retry that follows attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < math.MaxInt64/2; i++ {
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			break
+		}
+		d *= 2
+	}
+
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * rand.Float64() * float64(d))
+	}
+
+	return d
+}
@@ -0,0 +1,45 @@
+package sftpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	dialErr := func(err error) error {
+		return fmt.Errorf("failed to dial SFTP server: %w", &net.OpError{Op: "dial", Net: "tcp", Err: err})
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"connection refused", dialErr(os.NewSyscallError("connect", syscall.ECONNREFUSED)), true},
+		{"connection reset", dialErr(syscall.ECONNRESET), true},
+		{"connection lost", fmt.Errorf("failed to copy data to remote file: %w", io.ErrUnexpectedEOF), true},
+		{"dial timeout", dialErr(&timeoutError{op: "dial", d: time.Second}), true},
+		{"dns timeout", dialErr(&net.DNSError{Err: "timeout", Name: "sftp.example.com", IsTimeout: true}), true},
+		{"dns not found", dialErr(&net.DNSError{Err: "no such host", Name: "sftp.example.com", IsNotFound: true}), false},
+		{"permission denied", dialErr(os.NewSyscallError("connect", syscall.EACCES)), false},
+		{"host key", &HostKeyError{Hostname: "sftp.example.com"}, false},
+		{"cancelled", fmt.Errorf("%w: %w", context.Canceled, io.EOF), false},
+		{"other", errors.New("remote file size mismatch"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package sftpclient
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an in-process SSH server that serves SFTP from a temporary
+// directory.
+type testServer struct {
+	addr string
+	host string
+	port string
+	root string
+	// key is the PEM encoded private key of the user the server accepts.
+	key     []byte
+	hostKey ssh.PublicKey
+
+	mu sync.Mutex
+	// checkFile answers check-file-name requests if set. It is passed the
+	// requested path, relative to root.
+	checkFile func(p string) ([]byte, error)
+}
+
+// newTestServer starts a test server that accepts the public key in its key
+// field. opts may change the server config, e.g. to accept passwords. The
+// server is stopped when the test ends.
+func newTestServer(t testing.TB, opts ...func(*ssh.ServerConfig)) *testServer {
+	t.Helper()
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userPub, userPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(userPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshUserPub, err := ssh.NewPublicKey(userPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), sshUserPub.Marshal()) {
+				return nil, errUnknownKey
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	ts := &testServer{
+		addr:    l.Addr().String(),
+		root:    t.TempDir(),
+		key:     pem.EncodeToMemory(block),
+		hostKey: hostSigner.PublicKey(),
+	}
+	ts.host, ts.port, _ = net.SplitHostPort(ts.addr)
+
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go ts.serve(nc, config)
+		}
+	}()
+
+	return ts
+}
+
+var errUnknownKey = errors.New("unknown public key")
+
+// withECDSAHostKey returns a server option that adds an ECDSA host key, and
+// the public key.
+func withECDSAHostKey(t testing.TB) (func(*ssh.ServerConfig), ssh.PublicKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func(config *ssh.ServerConfig) { config.AddHostKey(signer) }, signer.PublicKey()
+}
+
+// serve runs the SFTP subsystem on every session channel of nc and forwards
+// direct-tcpip channels, so the server can also act as a jump host.
+func (ts *testServer) serve(nc net.Conn, config *ssh.ServerConfig) {
+	conn, chans, reqs, err := ssh.NewServerConn(nc, config)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for nch := range chans {
+		if nch.ChannelType() == "direct-tcpip" {
+			go forward(nch)
+			continue
+		}
+
+		if nch.ChannelType() != "session" {
+			_ = nch.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+
+		ch, chReqs, err := nch.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func() {
+			for req := range chReqs {
+				_ = req.Reply(req.Type == "subsystem", nil)
+			}
+		}()
+
+		var rwc io.ReadWriteCloser = ch
+		if hash := ts.checkFileHandler(); hash != nil {
+			rwc = newCheckFileConn(ch, hash)
+		}
+
+		go func() {
+			defer rwc.Close()
+			srv, err := sftp.NewServer(rwc, sftp.WithServerWorkingDirectory(ts.root))
+			if err != nil {
+				return
+			}
+			_ = srv.Serve()
+		}()
+	}
+}
+
+// handleCheckFile makes the server advertise the check-file extension and
+// answer check-file-name requests with the hash returned by hash.
+func (ts *testServer) handleCheckFile(hash func(p string) ([]byte, error)) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.checkFile = hash
+}
+
+func (ts *testServer) checkFileHandler() func(p string) ([]byte, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.checkFile
+}
+
+// checkFileConn sits between an SFTP channel and the SFTP server, which does
+// not support the check-file extension. It adds the extension to the
+// server's version packet and answers check-file-name requests itself.
+type checkFileConn struct {
+	ch    ssh.Channel
+	hash  func(p string) ([]byte, error)
+	in    *io.PipeReader
+	out   *io.PipeWriter
+	mu    sync.Mutex
+	close sync.Once
+}
+
+func newCheckFileConn(ch ssh.Channel, hash func(p string) ([]byte, error)) *checkFileConn {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &checkFileConn{ch: ch, hash: hash, in: inR, out: outW}
+
+	go func() {
+		inW.CloseWithError(c.filterRequests(inW))
+	}()
+	go func() {
+		outR.CloseWithError(c.filterResponses(outR))
+	}()
+
+	return c
+}
+
+func (c *checkFileConn) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c *checkFileConn) Write(p []byte) (int, error) { return c.out.Write(p) }
+
+func (c *checkFileConn) Close() error {
+	c.close.Do(func() { _ = c.out.Close() })
+	return c.ch.Close()
+}
+
+// filterRequests forwards packets from the client to w, except for
+// check-file-name requests, which it answers.
+func (c *checkFileConn) filterRequests(w io.Writer) error {
+	for {
+		pkt, err := readRawSFTPPacket(c.ch)
+		if err != nil {
+			return err
+		}
+
+		if pkt[4] == sftpPacketExtended {
+			r := bytes.NewReader(pkt[5:])
+			id, _ := readUint32(r)
+			if name, _ := readString(r); name == "check-file-name" {
+				p, _ := readString(r)
+				if err := c.send(c.checkFileReply(id, p)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if _, err := w.Write(pkt); err != nil {
+			return err
+		}
+	}
+}
+
+// checkFileReply returns the reply to a check-file-name request for p.
+func (c *checkFileConn) checkFileReply(id uint32, p string) []byte {
+	var b bytes.Buffer
+	writeUint32(&b, id)
+
+	sum, err := c.hash(p)
+	if err != nil {
+		writeUint32(&b, 2) // SSH_FX_NO_SUCH_FILE
+		writeString(&b, err.Error())
+		writeString(&b, "")
+		return sftpPacket(sftpPacketStatus, b.Bytes())
+	}
+
+	writeString(&b, "sha256")
+	b.Write(sum)
+	return sftpPacket(sftpPacketExtendedReply, b.Bytes())
+}
+
+// filterResponses forwards packets from the SFTP server read from r to the
+// client, adding the check-file extension to the version packet.
+func (c *checkFileConn) filterResponses(r io.Reader) error {
+	for {
+		pkt, err := readRawSFTPPacket(r)
+		if err != nil {
+			return err
+		}
+
+		if pkt[4] == sftpPacketVersion {
+			var b bytes.Buffer
+			b.Write(pkt[5:])
+			writeString(&b, checkFileExtension)
+			writeString(&b, "1")
+			pkt = sftpPacket(sftpPacketVersion, b.Bytes())
+		}
+
+		if err := c.send(pkt); err != nil {
+			return err
+		}
+	}
+}
+
+// send writes a whole packet to the client.
+func (c *checkFileConn) send(pkt []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.ch.Write(pkt)
+	return err
+}
+
+// readRawSFTPPacket reads an SFTP packet including its length.
+func readRawSFTPPacket(r io.Reader) ([]byte, error) {
+	var n [4]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(n[:])
+	if size == 0 {
+		return nil, errors.New("empty sftp packet")
+	}
+
+	pkt := make([]byte, 4+size)
+	copy(pkt, n[:])
+	if _, err := io.ReadFull(r, pkt[4:]); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
+
+// sftpPacket returns an SFTP packet of type typ.
+func sftpPacket(typ byte, payload []byte) []byte {
+	var b bytes.Buffer
+	_ = writeSFTPPacket(&b, typ, payload)
+	return b.Bytes()
+}
+
+// forward connects a direct-tcpip channel to the address it asks for.
+func forward(nch ssh.NewChannel) {
+	var req struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(nch.ExtraData(), &req); err != nil {
+		_ = nch.Reject(ssh.ConnectionFailed, "invalid direct-tcpip request")
+		return
+	}
+
+	target, err := net.Dial("tcp", net.JoinHostPort(req.Host, strconv.Itoa(int(req.Port))))
+	if err != nil {
+		_ = nch.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	ch, chReqs, err := nch.Accept()
+	if err != nil {
+		_ = target.Close()
+		return
+	}
+	go ssh.DiscardRequests(chReqs)
+
+	go func() {
+		_, _ = io.Copy(ch, target)
+		_ = ch.CloseWrite()
+	}()
+	_, _ = io.Copy(target, ch)
+	_ = target.Close()
+	_ = ch.Close()
+}
+
+// client returns a client for ts that verifies the server's host key and
+// authenticates with ts.key.
+func (ts *testServer) client(t testing.TB, opts ...Option) *OCEOSFTPClient {
+	t.Helper()
+	return ts.clientWithKey(t, ts.key, opts...)
+}
+
+// clientWithKey is like client but authenticates with key, which may be nil
+// if opts configure another auth method.
+func (ts *testServer) clientWithKey(t testing.TB, key []byte, opts ...Option) *OCEOSFTPClient {
+	t.Helper()
+
+	fp := ssh.FingerprintSHA256(ts.hostKey)
+	opts = append([]Option{WithHostKeyFingerprints(fp)}, opts...)
+	c, err := NewOCEOSFTPCLient(ts.host, ts.port, "test", key, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
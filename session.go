@@ -0,0 +1,277 @@
+package sftpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultKeepAliveInterval = 30 * time.Second
+)
+
+// session is an SSH connection and SFTP client shared by uploads.
+type session struct {
+	conn *ssh.Client
+	sc   *sftp.Client
+	// algorithms are the algorithms negotiated for conn.
+	algorithms NegotiatedAlgorithms
+	logger     *slog.Logger
+	// done is closed once the connection has shut down.
+	done chan struct{}
+	// dirs holds the remote directories known to exist.
+	dirs sync.Map
+
+	closeOnce sync.Once
+	closeErr  error
+
+	// failed holds the reason the session was closed by a timeout or a failed
+	// keepalive, if it was.
+	failOnce sync.Once
+	failed   atomic.Value
+}
+
+func newSession(conn *ssh.Client, sc *sftp.Client, algs NegotiatedAlgorithms,
+	keepAlive time.Duration, logger *slog.Logger) *session {
+	sess := &session{
+		conn:       conn,
+		sc:         sc,
+		algorithms: algs,
+		logger:     logger,
+		done:       make(chan struct{}),
+	}
+
+	go func() {
+		_ = sc.Wait()
+		close(sess.done)
+	}()
+
+	if keepAlive > 0 {
+		go sess.keepAlive(keepAlive)
+	}
+
+	return sess
+}
+
+// keepAlive periodically sends keepalive@openssh.com requests and closes the
+// connection if one fails or is not answered within interval, so a dead
+// connection is noticed before it is reused and uploads on it fail promptly.
+func (sess *session) keepAlive(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-sess.done:
+			return
+		case <-t.C:
+			wd := startWatchdog("SFTP keepalive", interval, sess.fail)
+			_, _, err := sess.conn.SendRequest("keepalive@openssh.com", true, nil)
+			if !wd.stop() {
+				return
+			}
+
+			if err != nil {
+				sess.logger.Warn("SFTP keepalive failed", slog.Any(logKeyError, err))
+				sess.fail(fmt.Errorf("SFTP keepalive failed: %w", err))
+				return
+			}
+		}
+	}
+}
+
+// fail closes the session because of err, which is reported by failure.
+func (sess *session) fail(err error) {
+	sess.failOnce.Do(func() {
+		sess.failed.Store(err)
+	})
+	_ = sess.close()
+}
+
+// failure returns the error the session was failed with, if any.
+func (sess *session) failure() error {
+	err, _ := sess.failed.Load().(error)
+	return err
+}
+
+// mkdirAll creates dir and its parents on the server unless they are known
+// to exist already.
+func (sess *session) mkdirAll(dir string) error {
+	if _, ok := sess.dirs.Load(dir); ok {
+		return nil
+	}
+
+	if err := sess.sc.MkdirAll(dir); err != nil {
+		return fmt.Errorf("failed to create remote dir %s: %w", dir, err)
+	}
+
+	sess.dirs.Store(dir, struct{}{})
+	return nil
+}
+
+// closed reports whether the connection has shut down.
+func (sess *session) closed() bool {
+	select {
+	case <-sess.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (sess *session) close() error {
+	sess.closeOnce.Do(func() {
+		// Closing the connection first keeps sc.Close from blocking on a dead
+		// link; the SFTP client then shuts down on the resulting EOF.
+		err := sess.conn.Close()
+		if errors.Is(err, net.ErrClosed) {
+			err = nil
+		}
+		_ = sess.sc.Close()
+		sess.closeErr = err
+	})
+	return sess.closeErr
+}
+
+// dialCall is a connection attempt that concurrent callers of session wait on.
+type dialCall struct {
+	done chan struct{}
+	sess *session
+	err  error
+	// cancelled is set if the attempt failed because the ctx of the caller
+	// that made it was done, so that other callers try again.
+	cancelled bool
+}
+
+// session returns the shared session, opening a new one if there is none or
+// the current one has gone stale. Only one caller connects at a time, and
+// callers waiting for it to finish give up when their ctx is done.
+//
+// Returns:
+// - The session.
+// - Whether the session was reused rather than freshly opened.
+// - An error if a new session could not be opened.
+func (s *OCEOSFTPClient) session(ctx context.Context) (*session, bool, error) {
+	for {
+		s.mu.Lock()
+		if s.sess != nil {
+			if !s.sess.closed() {
+				sess := s.sess
+				s.mu.Unlock()
+				return sess, true, nil
+			}
+
+			_ = s.sess.close()
+			s.sess = nil
+		}
+
+		call := s.dialing
+		if call == nil {
+			call = &dialCall{done: make(chan struct{})}
+			s.dialing = call
+			s.mu.Unlock()
+			return s.dialSession(ctx, call)
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-call.done:
+		}
+
+		if call.err == nil {
+			return call.sess, false, nil
+		}
+
+		if !call.cancelled {
+			return nil, false, call.err
+		}
+	}
+}
+
+// dialSession opens a new shared session for call without holding s.mu.
+func (s *OCEOSFTPClient) dialSession(ctx context.Context, call *dialCall) (*session, bool, error) {
+	conn, sc, algs, err := s.connect(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		s.sess = newSession(conn, sc, algs, s.keepAlive, s.logger)
+		call.sess = s.sess
+	}
+
+	call.err = err
+	call.cancelled = ctx.Err() != nil
+	s.dialing = nil
+	close(call.done)
+
+	return call.sess, false, err
+}
+
+// dropSession closes sess and forgets it if it is still the shared session.
+func (s *OCEOSFTPClient) dropSession(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sess == sess {
+		s.sess = nil
+	}
+
+	if err := sess.close(); err != nil {
+		s.logger.Warn("failed to close SFTP session", slog.Any(logKeyError, err))
+	}
+}
+
+// Close closes the connection to the SFTP server. Uploads that are still in
+// progress fail. A later upload opens a new connection.
+//
+// Returns:
+// - An error if closing the connection fails.
+func (s *OCEOSFTPClient) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sess == nil {
+		return nil
+	}
+
+	err := s.sess.close()
+	s.sess = nil
+	return err
+}
+
+// WithKeepAlive sets how often keepalive requests are sent. A request that is
+// not answered before the next one is due closes the connection, failing any
+// upload on it with ErrTimeout. A non-positive interval disables keepalives.
+//
+// Parameters:
+// - interval: Time between keepalive requests. Defaults to 30 seconds.
+//
+// Returns:
+// - An Option that sets the keepalive interval.
+func WithKeepAlive(interval time.Duration) Option {
+	return func(s *OCEOSFTPClient) error {
+		s.keepAlive = interval
+		return nil
+	}
+}
+
+// isConnectionLost reports whether err means the connection to the server is gone.
+func isConnectionLost(err error) bool {
+	return errors.Is(err, sftp.ErrSSHFxConnectionLost) ||
+		errors.Is(err, sftp.ErrSSHFxNoConnection) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed)
+}
@@ -0,0 +1,115 @@
+package sftpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Maritime-AI/oceo-sftp-csv-go/models"
+)
+
+func testCrew(id int) *models.Crew {
+	return &models.Crew{
+		ContextID:      "ctx",
+		CrewExternalID: fmt.Sprintf("crew-%d", id),
+		FirstName:      "First",
+		LastName:       "Last",
+	}
+}
+
+func TestCancelledUploadDoesNotCloseSharedSession(t *testing.T) {
+	grace := cancelGracePeriod
+	cancelGracePeriod = 50 * time.Millisecond
+	t.Cleanup(func() { cancelGracePeriod = grace })
+
+	ts := newTestServer(t)
+	c := ts.client(t)
+
+	// Cancel an upload mid-stream.
+	ctx, cancel := context.WithCancel(context.Background())
+	records := make(chan *models.Crew)
+	errc := make(chan error, 1)
+	go func() { errc <- UploadChan(ctx, c, "org", records) }()
+	records <- testCrew(1)
+	records <- testCrew(2)
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled upload returned %v, want context.Canceled", err)
+	}
+
+	// An upload on the same connection that outlasts the grace period must
+	// not be aborted by the cancelled one.
+	records = make(chan *models.Crew)
+	go func() {
+		defer close(records)
+		for i := 0; i < 4; i++ {
+			records <- testCrew(i)
+			time.Sleep(cancelGracePeriod)
+		}
+	}()
+
+	if err := UploadChan(context.Background(), c, "org", records); err != nil {
+		t.Fatalf("upload after cancelled upload failed: %v", err)
+	}
+}
+
+func TestSessionWaitHonorsContext(t *testing.T) {
+	// The server accepts connections but never answers the handshake.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = nc.Close() })
+		}
+	}()
+
+	ts := newTestServer(t)
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	c, err := NewOCEOSFTPCLient(host, port, "test", ts.key, WithInsecureIgnoreHostKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dialCtx, cancelDial := context.WithCancel(context.Background())
+	dialErr := make(chan error, 1)
+	go func() {
+		_, _, err := c.session(dialCtx)
+		dialErr <- err
+	}()
+
+	// Wait until the first caller is connecting.
+	for {
+		c.mu.Lock()
+		dialing := c.dialing != nil
+		c.mu.Unlock()
+		if dialing {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := c.session(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("session returned %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("session returned after %s, want it to honor its ctx", d)
+	}
+
+	cancelDial()
+	if err := <-dialErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("connecting caller returned %v, want context.Canceled", err)
+	}
+}
@@ -3,9 +3,17 @@ package sftpclient
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/Maritime-AI/oceo-sftp-csv-go/models"
@@ -15,29 +23,77 @@ import (
 )
 
 const (
-	remoteDir = "./data"
+	defaultRemoteDir = "./data"
+
+	// partSuffix ends the name of the temporary file that a remote file is
+	// written to, after the remote file name and a random suffix.
+	partSuffix = ".part"
 )
 
-type FileType string
+// cancelGracePeriod is how long a cancelled upload may spend removing its
+// partial remote file before the connection is forcibly closed.
+var cancelGracePeriod = 5 * time.Second
+
+// FileType identifies the kind of records held in an uploaded file.
+type FileType = models.FileType
 
 const (
-	FileTypeCrew                    FileType = "crew"
-	FileTypeCrewCredentials         FileType = "credentials"
-	FileTypeVessels                 FileType = "vessels"
-	FileTypeVesselSchedules         FileType = "vesselschedules"
-	FileTypeVesselSchedulePositions FileType = "vesselschedulepositions"
-	FileTypeCrewSchedules           FileType = "crewschedules"
-	FileTypeCrewSchedulePositions   FileType = "crewschedulepositions"
+	FileTypeCrew                    = models.FileTypeCrew
+	FileTypeCrewCredentials         = models.FileTypeCrewCredentials
+	FileTypeCrewSeatime             = models.FileTypeCrewSeatime
+	FileTypeVessels                 = models.FileTypeVessels
+	FileTypeVesselSchedules         = models.FileTypeVesselSchedules
+	FileTypeVesselSchedulePositions = models.FileTypeVesselSchedulePositions
+	FileTypeCrewSchedules           = models.FileTypeCrewSchedules
+	FileTypeCrewSchedulePositions   = models.FileTypeCrewSchedulePositions
 )
 
 const (
-	fileTemplate = "%s_%s_%d.csv"
+	fileTemplate = "%s_%s_%d_%s.csv"
 )
 
 // OCEOSFTPClient manages the connection to an SFTP server and provides methods to upload structured data in CSV format.
+//
+// An OCEOSFTPClient keeps a single connection open between uploads and is safe
+// for concurrent use. Call Close to release the connection.
 type OCEOSFTPClient struct {
-	addr   string
-	config ssh.ClientConfig
+	addr      string
+	dialer    Dialer
+	jumpHosts []JumpHost
+	config    ssh.ClientConfig
+	auth      map[AuthMethod]ssh.AuthMethod
+	agentSock string
+	authOrder []AuthMethod
+	// knownKeyTypes, if set, returns the types of the keys known for a host.
+	knownKeyTypes func(hostname string) map[string]bool
+
+	keyPassphrase []byte
+	cert          *ssh.Certificate
+	certFunc      func() ([]byte, error)
+
+	keepAlive        time.Duration
+	dialTimeout      time.Duration
+	handshakeTimeout time.Duration
+	idleTimeout      time.Duration
+	retry            RetryPolicy
+
+	remoteDir    string
+	fileTypeDirs map[FileType]string
+	fileName     FileNameFunc
+	noOverwrite  bool
+	checksumFile bool
+	maxRows      int
+	maxBytes     int64
+	onUpload     func(UploadResult)
+	logger       *slog.Logger
+
+	gzip      bool
+	gzipLevel int
+	pgp       *pgpEncrypter
+
+	mu      sync.Mutex
+	sess    *session
+	dialing *dialCall
 }
 
 // NewOCEOSFTPCLient initializes a new OCEO SFTPClient with the specified server details.
@@ -47,59 +103,166 @@ type OCEOSFTPClient struct {
 // - host: The SFTP server address.
 // - port: The port on which the SFTP server is running.
 // - user: The username for authentication.
-// - rsaPrivateKeyBytes: RSA private key in byte representation.
+// - privateKeyBytes: PEM or OpenSSH encoded private key of any type supported
+// by golang.org/x/crypto/ssh, such as RSA, ECDSA or Ed25519. Use
+// WithPrivateKeyPassphrase for an encrypted key and WithCertificate or
+// WithCertificateFunc to add an OpenSSH certificate. It may be nil if another
+// authentication method, such as WithPassword, is configured.
+// - opts: Optional settings. One host key option is required:
+// WithKnownHostsFile, WithHostKeyFingerprints, WithTrustOnFirstUse or, to
+// skip verification, WithInsecureIgnoreHostKey.
 //
 // Returns:
 // - An instance of SFTPClient.
 // - An error if there is an issue creating the client.
 func NewOCEOSFTPCLient(
 	host, port, user string,
-	rsaPrivateKeyBytes []byte) (*OCEOSFTPClient, error) {
-	authMethod, err := readPrivateKey(rsaPrivateKeyBytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read private key: %w", err)
-	}
-
+	privateKeyBytes []byte, opts ...Option) (*OCEOSFTPClient, error) {
 	addr := fmt.Sprintf("%s:%s", host, port)
-	return &OCEOSFTPClient{
-		addr: addr,
+	s := &OCEOSFTPClient{
+		addr:   addr,
+		dialer: &net.Dialer{},
 		config: ssh.ClientConfig{
-			User:            user,
-			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-			Auth:            authMethod,
+			User: user,
 		},
-	}, nil
+		auth:             make(map[AuthMethod]ssh.AuthMethod),
+		keepAlive:        defaultKeepAliveInterval,
+		dialTimeout:      defaultDialTimeout,
+		handshakeTimeout: defaultHandshakeTimeout,
+		idleTimeout:      defaultIdleTimeout,
+		remoteDir:        defaultRemoteDir,
+		fileName:         DefaultFileName,
+		logger:           slog.New(discardHandler{}),
+	}
+
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, fmt.Errorf("failed to apply option: %w", err)
+		}
+	}
+
+	if len(privateKeyBytes) > 0 {
+		signer, err := readPrivateKey(privateKeyBytes, s.keyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+
+		authMethod, err := s.publicKeyAuth(signer)
+		if err != nil {
+			return nil, err
+		}
+		s.auth[AuthPublicKey] = authMethod
+	} else if s.cert != nil || s.certFunc != nil {
+		return nil, errors.New("certificate without private key")
+	}
+
+	if err := s.checkAuth(); err != nil {
+		return nil, err
+	}
+
+	if s.config.HostKeyCallback == nil {
+		return nil, errors.New("missing host key verification")
+	}
+
+	return s, nil
 }
 
-// UploadCrewFile uploads a slice of Crew data to the SFTP server as a CSV file.
+// Upload validates records, marshals them to CSV and uploads them to the SFTP
+// server as a file named after orgName and the records' FileType.
+//
+// If any record is invalid nothing is uploaded and a *models.ValidationError
+// listing every invalid row is returned.
 //
 // Parameters:
-// - crew: A slice of Crew structs.
+// - s: The client to upload with.
+// - orgName: The name of your organization.
+// - records: The records to upload, pointers to models such as *models.Crew.
+// T must not be an interface type such as models.Record, since the CSV columns
+// come from its fields.
 //
 // Returns:
 // - An error if the upload fails.
-func (s *OCEOSFTPClient) UploadCrewFile(ctx context.Context,
-	orgName string, crew ...models.Crew) error {
-	if len(crew) == 0 {
-		fmt.Println("No crew to upload")
+func Upload[T models.Record](ctx context.Context, s *OCEOSFTPClient,
+	orgName string, records ...T) error {
+	fileType, err := recordFileType[T]()
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		s.logNothingToUpload(ctx, orgName, fileType)
 		return nil
 	}
 
-	for _, c := range crew {
-		if err := c.Validate(); err != nil {
-			return fmt.Errorf("invalid crew data: %w", err)
-		}
+	t := time.Now()
+	files, err := marshalFiles(s, orgName, t, records)
+	if err != nil {
+		return err
+	}
+
+	entries, err := s.uploadFiles(ctx, orgName, files)
+	if err != nil || !s.splitting() {
+		return err
+	}
+
+	_, err = s.uploadManifest(ctx, orgName, t, entries)
+	return err
+}
+
+// file is a marshalled file that is ready to be uploaded.
+type file struct {
+	fileType FileType
+	// name is the path of the file relative to the remote directory.
+	name string
+	rows int
+	data []byte
+}
+
+// marshalFiles validates records and marshals them to CSV. It returns a
+// single file, or the parts of the file if the client splits uploads.
+func marshalFiles[T models.Record](s *OCEOSFTPClient, orgName string,
+	t time.Time, records []T) ([]*file, error) {
+	fileType, err := recordFileType[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := models.ValidateRecords(records); err != nil {
+		return nil, err
 	}
 
-	nowUnix := time.Now().Unix()
-	fn := fmt.Sprintf(fileTemplate, orgName, FileTypeCrew, nowUnix)
+	name, err := s.baseName(orgName, fileType, t)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.splitting() {
+		return splitFile(s, name, records)
+	}
 
-	bs, err := gocsv.MarshalBytes(&crew)
+	bs, err := gocsv.MarshalBytes(normalizeAll(records))
 	if err != nil {
-		return fmt.Errorf("failed to marshal crew: %w", err)
+		return nil, fmt.Errorf("failed to marshal %s: %w", fileType, err)
 	}
 
-	return s.uploadData(fn, bs)
+	return []*file{{
+		fileType: fileType,
+		name:     s.filePath(fileType, name, 0),
+		rows:     len(records),
+		data:     bs,
+	}}, nil
+}
+
+// UploadCrewFile uploads a slice of Crew data to the SFTP server as a CSV file.
+//
+// Parameters:
+// - crew: A slice of Crew structs.
+//
+// Returns:
+// - An error if the upload fails.
+func (s *OCEOSFTPClient) UploadCrewFile(ctx context.Context,
+	orgName string, crew ...models.Crew) error {
+	return Upload(ctx, s, orgName, pointers(crew)...)
 }
 
 // UploadCrewCredentialFile uploads a slice of CrewCredential data to the SFTP server as a CSV file.
@@ -111,26 +274,19 @@ func (s *OCEOSFTPClient) UploadCrewFile(ctx context.Context,
 // - An error if the upload fails.
 func (s *OCEOSFTPClient) UploadCrewCredentialFile(ctx context.Context,
 	orgName string, credentials ...models.CrewCredential) error {
-	if len(credentials) == 0 {
-		fmt.Println("No crew to upload")
-		return nil
-	}
-
-	for _, cc := range credentials {
-		if err := cc.Validate(); err != nil {
-			return fmt.Errorf("invalid crew credential data: %w", err)
-		}
-	}
-
-	nowUnix := time.Now().Unix()
-	fn := fmt.Sprintf(fileTemplate, orgName, FileTypeCrewCredentials, nowUnix)
-
-	bs, err := gocsv.MarshalBytes(&credentials)
-	if err != nil {
-		return fmt.Errorf("failed to marshal crew credentials: %w", err)
-	}
+	return Upload(ctx, s, orgName, pointers(credentials)...)
+}
 
-	return s.uploadData(fn, bs)
+// UploadCrewSeatimeFile uploads a slice of CrewSeatime data to the SFTP server as a CSV file.
+//
+// Parameters:
+// - seatime: A slice of CrewSeatime structs.
+//
+// Returns:
+// - An error if the upload fails.
+func (s *OCEOSFTPClient) UploadCrewSeatimeFile(ctx context.Context,
+	orgName string, seatime ...models.CrewSeatime) error {
+	return Upload(ctx, s, orgName, pointers(seatime)...)
 }
 
 // UploadVesselFile uploads a slice of Vessel data to the SFTP server as a CSV file.
@@ -142,26 +298,7 @@ func (s *OCEOSFTPClient) UploadCrewCredentialFile(ctx context.Context,
 // - An error if the upload fails.
 func (s *OCEOSFTPClient) UploadVesselFile(ctx context.Context,
 	orgName string, vessels ...models.Vessel) error {
-	if len(vessels) == 0 {
-		fmt.Println("No vessels to upload")
-		return nil
-	}
-
-	for _, v := range vessels {
-		if err := v.Validate(); err != nil {
-			return fmt.Errorf("invalid vessel data: %w", err)
-		}
-	}
-
-	nowUnix := time.Now().Unix()
-	fn := fmt.Sprintf(fileTemplate, orgName, FileTypeVessels, nowUnix)
-
-	bs, err := gocsv.MarshalBytes(&vessels)
-	if err != nil {
-		return fmt.Errorf("failed to marshal vessels: %w", err)
-	}
-
-	return s.uploadData(fn, bs)
+	return Upload(ctx, s, orgName, pointers(vessels)...)
 }
 
 // UploadVesselScheduleFile uploads a slice of VesselSchedule data to the SFTP server as a CSV file.
@@ -173,26 +310,7 @@ func (s *OCEOSFTPClient) UploadVesselFile(ctx context.Context,
 // - An error if the upload fails.
 func (s *OCEOSFTPClient) UploadVesselScheduleFile(ctx context.Context,
 	orgName string, vesselSchedules ...models.VesselSchedule) error {
-	if len(vesselSchedules) == 0 {
-		fmt.Println("No vessel schedules to upload")
-		return nil
-	}
-
-	for _, vs := range vesselSchedules {
-		if err := vs.Validate(); err != nil {
-			return fmt.Errorf("invalid vessel schedule data: %w", err)
-		}
-	}
-
-	nowUnix := time.Now().Unix()
-	fn := fmt.Sprintf(fileTemplate, orgName, FileTypeVesselSchedules, nowUnix)
-
-	bs, err := gocsv.MarshalBytes(&vesselSchedules)
-	if err != nil {
-		return fmt.Errorf("failed to marshal vessel schedules: %w", err)
-	}
-
-	return s.uploadData(fn, bs)
+	return Upload(ctx, s, orgName, pointers(vesselSchedules)...)
 }
 
 // UploadVesselSchedulePositionFile uploads a slice of VesselSchedulePosition data to the SFTP server as a CSV file.
@@ -204,26 +322,7 @@ func (s *OCEOSFTPClient) UploadVesselScheduleFile(ctx context.Context,
 // - An error if the upload fails.
 func (s *OCEOSFTPClient) UploadVesselSchedulePositionFile(ctx context.Context,
 	orgName string, vesselPositions ...models.VesselSchedulePosition) error {
-	if len(vesselPositions) == 0 {
-		fmt.Println("No vessel positions to upload")
-		return nil
-	}
-
-	for _, vp := range vesselPositions {
-		if err := vp.Validate(); err != nil {
-			return fmt.Errorf("invalid vessel position data: %w", err)
-		}
-	}
-
-	nowUnix := time.Now().Unix()
-	fn := fmt.Sprintf(fileTemplate, orgName, FileTypeVesselSchedulePositions, nowUnix)
-
-	bs, err := gocsv.MarshalBytes(&vesselPositions)
-	if err != nil {
-		return fmt.Errorf("failed to marshal vessel positions: %w", err)
-	}
-
-	return s.uploadData(fn, bs)
+	return Upload(ctx, s, orgName, pointers(vesselPositions)...)
 }
 
 // UploadCrewScheduleFile uploads a slice of CrewSchedule data to the SFTP server as a CSV file.
@@ -235,26 +334,7 @@ func (s *OCEOSFTPClient) UploadVesselSchedulePositionFile(ctx context.Context,
 // - An error if the upload fails.
 func (s *OCEOSFTPClient) UploadCrewScheduleFile(ctx context.Context, orgName string,
 	crewSchedules ...models.CrewSchedule) error {
-	if len(crewSchedules) == 0 {
-		fmt.Println("No crew schedules to upload")
-		return nil
-	}
-
-	for _, cs := range crewSchedules {
-		if err := cs.Validate(); err != nil {
-			return fmt.Errorf("invalid crew schedule data: %w", err)
-		}
-	}
-
-	nowUnix := time.Now().Unix()
-	fn := fmt.Sprintf(fileTemplate, orgName, FileTypeCrewSchedules, nowUnix)
-
-	bs, err := gocsv.MarshalBytes(&crewSchedules)
-	if err != nil {
-		return fmt.Errorf("failed to marshal crew schedules: %w", err)
-	}
-
-	return s.uploadData(fn, bs)
+	return Upload(ctx, s, orgName, pointers(crewSchedules)...)
 }
 
 // UploadCrewSchedulePositionFile uploads a slice of CrewSchedulePosition data to the SFTP server as a CSV file.
@@ -266,86 +346,434 @@ func (s *OCEOSFTPClient) UploadCrewScheduleFile(ctx context.Context, orgName str
 // - An error if the upload fails.
 func (s *OCEOSFTPClient) UploadCrewSchedulePositionFile(ctx context.Context, orgName string,
 	crewSchedulePositions ...models.CrewSchedulePosition) error {
-	if len(crewSchedulePositions) == 0 {
-		fmt.Println("No crew schedule positions to upload")
-		return nil
-	}
+	return Upload(ctx, s, orgName, pointers(crewSchedulePositions)...)
+}
 
-	for _, csp := range crewSchedulePositions {
-		if err := csp.Validate(); err != nil {
-			return fmt.Errorf("invalid crew schedule position data: %w", err)
-		}
-	}
+// uploaded describes a file as it was written to the server.
+type uploaded struct {
+	size       int64
+	sha256     string
+	algorithms NegotiatedAlgorithms
+}
 
-	nowUnix := time.Now().Unix()
-	fn := fmt.Sprintf(fileTemplate, orgName, FileTypeCrewSchedulePositions, nowUnix)
+// UploadResult describes a file written to the SFTP server, for audit logs.
+type UploadResult struct {
+	// RemotePath is the full path of the file on the server.
+	RemotePath string
+	ByteSize   int64
+	SHA256     string
+	// Algorithms are the SSH algorithms of the connection the file was sent over.
+	Algorithms NegotiatedAlgorithms
+}
 
-	bs, err := gocsv.MarshalBytes(&crewSchedulePositions)
-	if err != nil {
-		return fmt.Errorf("failed to marshal crew schedule positions: %w", err)
-	}
+// WithUploadCallback calls fn after every file, including manifests, has been
+// written to the server.
+//
+// Parameters:
+// - fn: Receives the result of each upload.
+//
+// Returns:
+// - An Option that sets the upload callback.
+func WithUploadCallback(fn func(UploadResult)) Option {
+	return func(s *OCEOSFTPClient) error {
+		if fn == nil {
+			return errors.New("missing upload callback")
+		}
 
-	return s.uploadData(fn, bs)
+		s.onUpload = fn
+		return nil
+	}
 }
 
 // uploadData is a helper function to upload data of any type to the SFTP server as a CSV file.
 //
+// Missing parent directories of dest are created. The data is compressed if
+// the client is configured to do so. Failed uploads are retried according to
+// the client's retry policy.
+//
 // Parameters:
 // - data: The data to be uploaded, which must be a slice of structs.
 //
 // Returns:
-// - An error if the upload fails.
-func (s *OCEOSFTPClient) uploadData(fileName string, data []byte) error {
+// - The size and SHA-256 of the file written to the server.
+// - An error if the upload fails. It wraps ctx.Err() if ctx is done.
+func (s *OCEOSFTPClient) uploadData(ctx context.Context, dest string, data []byte) (*uploaded, error) {
+	var up *uploaded
+	err := s.retry.do(ctx, func() error {
+		var err error
+		up, err = s.uploadOnce(ctx, dest, func() io.ReadCloser {
+			return s.encode(data)
+		}, true)
+		return err
+	})
+	return up, err
+}
 
-	conn, err := ssh.Dial("tcp", s.addr, &s.config)
-	if err != nil {
-		return fmt.Errorf("failed to dial SFTP server: %w", err)
+// uploadOnce makes a single attempt at uploading the content returned by open
+// to dest. open is only called once the remote file has been created.
+//
+// The upload reuses the client's connection, opening it if needed. If a reused
+// connection turns out to be stale the upload is repeated once on a new one,
+// unless the content is not replayable and open has already been called.
+// Dialing, the SSH handshake, the SFTP session setup and the copy are all
+// aborted when ctx is done. A partially written remote file is removed.
+func (s *OCEOSFTPClient) uploadOnce(ctx context.Context, dest string,
+	open func() io.ReadCloser, replayable bool) (*uploaded, error) {
+	s.logger.LogAttrs(ctx, slog.LevelDebug, "uploading file", slog.String(logKeyRemotePath, dest))
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		sess, reused, err := s.session(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// If ctx is done mid-copy the reader stops at the next chunk, which leaves
+		// the session usable for removing the partial file. The connection is only
+		// torn down if this upload is still stuck after the grace period.
+		stop := closeAfterGrace(ctx, sess)
+
+		var up *uploaded
+		r := &lazyReader{open: open}
+		err = sess.mkdirAll(path.Dir(dest))
+		if err == nil {
+			up, err = s.putFile(ctx, sess, dest, r)
+			_ = r.Close()
+		}
+		if err == nil && s.checksumFile {
+			sidecar := bytes.NewReader(checksumLine(up.sha256, dest))
+			if _, err = s.putFile(ctx, sess, dest+checksumSuffix, sidecar); err != nil {
+				err = fmt.Errorf("failed to upload checksum file: %w", err)
+			}
+		}
+		stop()
+		if err != nil && ctx.Err() == nil {
+			if cause := sess.failure(); cause != nil {
+				err = fmt.Errorf("%w: %w", cause, err)
+			}
+		}
+
+		if err == nil {
+			up.algorithms = sess.algorithms
+			if s.onUpload != nil {
+				s.onUpload(UploadResult{
+					RemotePath: dest,
+					ByteSize:   up.size,
+					SHA256:     up.sha256,
+					Algorithms: up.algorithms,
+				})
+			}
+			return up, nil
+		}
+
+		if ctx.Err() == nil && (sess.closed() || isConnectionLost(err)) {
+			s.dropSession(sess)
+			if reused && (replayable || r.r == nil) {
+				s.logger.LogAttrs(ctx, slog.LevelWarn, "SFTP connection went stale, reconnecting",
+					slog.String(logKeyRemotePath, dest))
+				continue
+			}
+		}
+
+		return nil, contextError(ctx, err)
 	}
+}
 
-	defer func() {
-		if err := conn.Close(); err != nil {
-			log.Printf("failed to close SFTP connection: %v", err)
+// putFile atomically writes r to dest. The data is written to a temporary
+// file next to dest, its size and, if the server supports the check-file
+// extension, its SHA-256 are checked and it is then renamed into place, so
+// the server never sees a partially written dest. The temporary file is
+// removed if any step fails.
+//
+// The temporary file has a unique name and is created exclusively, so
+// concurrent uploads to the same dest never write to the same file. If the
+// client is configured not to overwrite files, an existing dest is reported
+// as ErrRemoteFileExists.
+func (s *OCEOSFTPClient) putFile(ctx context.Context, sess *session,
+	dest string, r io.Reader) (*uploaded, error) {
+	sc := sess.sc
+
+	// The idle timeout tears down the connection if the server stops
+	// responding, which unblocks whichever request is waiting on it.
+	wd := startWatchdog("SFTP server response", s.idleTimeout, sess.fail)
+	defer wd.stop()
+
+	// Checking up front saves sending data that could not be stored anyway.
+	// linkFile makes the final, race free check.
+	if s.noOverwrite {
+		if _, ok := sc.HasExtension("hardlink@openssh.com"); !ok {
+			return nil, ErrNoOverwriteUnsupported
 		}
-	}()
 
-	sc, err := sftp.NewClient(conn)
+		if _, err := sc.Stat(dest); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrRemoteFileExists, dest)
+		}
+	}
+
+	tmp := dest + "." + randomSuffix() + partSuffix
+	tmpFile, err := sc.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
 	if err != nil {
-		return fmt.Errorf("failed to create SFTP client: %w", err)
+		return nil, fmt.Errorf("failed to create remote file: %w", err)
 	}
 
-	defer func() {
-		if err := sc.Close(); err != nil {
-			log.Printf("failed to close SFTP client: %v", err)
+	removeTmp := func() {
+		if err := sc.Remove(tmp); err != nil {
+			s.logger.LogAttrs(ctx, slog.LevelWarn, "failed to remove partial remote file",
+				slog.String(logKeyRemotePath, tmp), slog.Any(logKeyError, err))
 		}
-	}()
+	}
 
-	// Open the destination file on the remote server
-	dest := fmt.Sprintf("./%s/%s", remoteDir, fileName)
-	log.Printf("uploading data to %s", dest)
-	destFile, err := sc.Create(dest)
+	// Copy the content to the remote file
+	h := sha256.New()
+	src := &idleReader{r: &contextReader{ctx: ctx, r: io.TeeReader(r, h)}, w: wd}
+	n, copyErr := io.Copy(tmpFile, src)
+	closeErr := tmpFile.Close()
+	if copyErr == nil && closeErr != nil {
+		copyErr = closeErr
+	}
+
+	if copyErr != nil {
+		removeTmp()
+		return nil, fmt.Errorf("failed to copy data to remote file: %w", copyErr)
+	}
+
+	info, err := sc.Stat(tmp)
 	if err != nil {
-		return fmt.Errorf("failed to create remote file: %w", err)
+		removeTmp()
+		return nil, fmt.Errorf("failed to stat remote file: %w", err)
+	}
+
+	if info.Size() != n {
+		removeTmp()
+		return nil, fmt.Errorf("remote file size mismatch: wrote %d bytes, remote has %d",
+			n, info.Size())
+	}
+
+	up := &uploaded{
+		size:   n,
+		sha256: hex.EncodeToString(h.Sum(nil)),
+	}
+
+	if err := verifySHA256(sess, tmp, up.sha256); err != nil {
+		removeTmp()
+		return nil, err
 	}
-	defer func() {
-		if err := destFile.Close(); err != nil {
-			log.Printf("failed to close remote file: %v", err)
+
+	if err := ctx.Err(); err != nil {
+		removeTmp()
+		return nil, err
+	}
+
+	if s.noOverwrite {
+		if err := s.linkFile(ctx, sc, tmp, dest); err != nil {
+			return nil, err
 		}
-	}()
+		return up, nil
+	}
 
-	// Copy the content to the remote file
-	if _, err := io.Copy(destFile, bytes.NewReader(data)); err != nil {
-		return fmt.Errorf("failed to copy data to remote file: %w", err)
+	if _, ok := sc.HasExtension("posix-rename@openssh.com"); ok {
+		err = sc.PosixRename(tmp, dest)
+	} else {
+		err = sc.Rename(tmp, dest)
+	}
+
+	if err != nil {
+		removeTmp()
+		return nil, fmt.Errorf("failed to rename remote file: %w", err)
+	}
+
+	return up, nil
+}
+
+// linkFile moves tmp to dest by hard linking it, which fails with
+// ErrRemoteFileExists if dest already exists. A plain SFTP rename is not used
+// as a fallback since many servers let it replace dest. tmp is removed in
+// either case.
+func (s *OCEOSFTPClient) linkFile(ctx context.Context, sc *sftp.Client, tmp, dest string) error {
+	err := sc.Link(tmp, dest)
+
+	if rmErr := sc.Remove(tmp); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+		s.logger.LogAttrs(ctx, slog.LevelWarn, "failed to remove partial remote file",
+			slog.String(logKeyRemotePath, tmp), slog.Any(logKeyError, rmErr))
+	}
+
+	if err != nil {
+		if _, statErr := sc.Stat(dest); statErr == nil {
+			return fmt.Errorf("%w: %s", ErrRemoteFileExists, dest)
+		}
+		return fmt.Errorf("failed to rename remote file: %w", err)
 	}
 
 	return nil
 }
 
-func readPrivateKey(keyBytes []byte) ([]ssh.AuthMethod, error) {
-	signer, err := ssh.ParsePrivateKey(keyBytes)
+// connect dials the SFTP server, through the configured proxy and jump hosts
+// if any, and opens an SFTP session. The connection is closed if ctx is done
+// before the session is ready.
+func (s *OCEOSFTPClient) connect(ctx context.Context) (*ssh.Client, *sftp.Client, NegotiatedAlgorithms, error) {
+	var algs NegotiatedAlgorithms
+	nc, err := s.dial(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, algs, contextError(ctx, fmt.Errorf("failed to dial SFTP server: %w", err))
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = nc.Close()
+	})
+
+	// The handshake timeout covers authentication and starting the SFTP
+	// subsystem as well as the SSH handshake itself.
+	wd := startWatchdog("SSH handshake", s.handshakeTimeout, func(error) {
+		_ = nc.Close()
+	})
+
+	config := s.config
+	config.HostKeyAlgorithms = s.hostKeyAlgorithms()
+	auth, release, err := s.authMethods()
+	if err != nil {
+		stop()
+		wd.stop()
+		_ = nc.Close()
+		return nil, nil, algs, fmt.Errorf("failed to dial SFTP server: %w", err)
+	}
+	config.Auth = auth
+
+	rec := &kexRecorder{Conn: nc}
+	c, chans, reqs, err := ssh.NewClientConn(rec, s.addr, &config)
+	release()
+	if err != nil {
+		stop()
+		wd.stop()
+		_ = nc.Close()
+		return nil, nil, algs, contextError(ctx, fmt.Errorf("failed to dial SFTP server: %w", wd.check(err)))
+	}
+	conn := ssh.NewClient(c, chans, reqs)
+
+	if algs, err = rec.negotiated(); err != nil {
+		s.logger.LogAttrs(ctx, slog.LevelWarn, "failed to determine negotiated SSH algorithms",
+			slog.Any(logKeyError, err))
+	}
+
+	sc, err := sftp.NewClient(conn)
+	if err != nil {
+		stop()
+		wd.stop()
+		_ = conn.Close()
+		return nil, nil, algs, contextError(ctx, fmt.Errorf("failed to create SFTP client: %w", wd.check(err)))
+	}
+
+	if !stop() {
+		wd.stop()
+		_ = sc.Close()
+		_ = conn.Close()
+		return nil, nil, algs, contextError(ctx, errors.New("failed to create SFTP client"))
+	}
+
+	if !wd.stop() {
+		_ = sc.Close()
+		_ = conn.Close()
+		return nil, nil, algs, fmt.Errorf("failed to create SFTP client: %w", wd.check(nil))
+	}
+
+	return conn, sc, algs, nil
+}
+
+// closeAfterGrace closes sess once ctx has been done for cancelGracePeriod.
+// The returned func stops this and must be called when the upload using sess
+// returns, so that other uploads sharing sess are not aborted.
+func closeAfterGrace(ctx context.Context, sess *session) func() {
+	var mu sync.Mutex
+	var timer *time.Timer
+	var stopped bool
+	stopCtx := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !stopped {
+			timer = time.AfterFunc(cancelGracePeriod, func() {
+				_ = sess.close()
+			})
+		}
+	})
+
+	return func() {
+		if stopCtx() {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		stopped = true
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// recordFileType returns the file type of records of type T. It fails if T is
+// an interface type, whose zero value has no file type and whose records
+// cannot be marshalled to CSV.
+func recordFileType[T models.Record]() (FileType, error) {
+	var zero T
+	if any(zero) == nil {
+		return "", fmt.Errorf("cannot upload records of interface type %s, use a concrete type such as *models.Crew",
+			reflect.TypeOf(&zero).Elem())
+	}
+	return zero.FileType(), nil
+}
+
+// pointers returns pointers to the elements of vs.
+func pointers[T any](vs []T) []*T {
+	ps := make([]*T, len(vs))
+	for i := range vs {
+		ps[i] = &vs[i]
+	}
+	return ps
+}
+
+// contextError wraps err with ctx.Err() when ctx is done, so callers can match
+// context.Canceled and context.DeadlineExceeded with errors.Is.
+func contextError(ctx context.Context, err error) error {
+	ctxErr := ctx.Err()
+	if ctxErr == nil || errors.Is(err, ctxErr) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ctxErr, err)
+}
+
+// lazyReader calls open on the first Read.
+type lazyReader struct {
+	open func() io.ReadCloser
+	r    io.ReadCloser
+}
+
+func (r *lazyReader) Read(p []byte) (int, error) {
+	if r.r == nil {
+		r.r = r.open()
+	}
+	return r.r.Read(p)
+}
+
+func (r *lazyReader) Close() error {
+	if r.r == nil {
+		return nil
+	}
+	return r.r.Close()
+}
+
+// contextReader stops reading once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
-	return []ssh.AuthMethod{
-		ssh.PublicKeys(signer),
-	}, nil
+	return r.r.Read(p)
 }
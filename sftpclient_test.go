@@ -0,0 +1,85 @@
+package sftpclient
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Maritime-AI/oceo-sftp-csv-go/models"
+)
+
+func TestUploadInterfaceType(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t)
+	records := []models.Record{testCrew(1)}
+
+	for name, upload := range map[string]func() error{
+		"Upload":      func() error { return Upload(context.Background(), c, "org", records...) },
+		"Upload none": func() error { return Upload[models.Record](context.Background(), c, "org") },
+		"UploadCSV": func() error {
+			return UploadCSV[models.Record](context.Background(), c, "org", strings.NewReader(""))
+		},
+		"UploadIter": func() error {
+			return UploadIter(context.Background(), c, "org", func() (models.Record, error) {
+				return testCrew(1), nil
+			})
+		},
+	} {
+		err := upload()
+		if err == nil || !strings.Contains(err.Error(), "interface type") {
+			t.Errorf("%s returned %v, want an interface type error", name, err)
+		}
+	}
+}
+
+func TestUploadSeatimeTimes(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, WithFileNameFunc(fixedFileName))
+
+	on := time.Date(2024, 1, 2, 3, 4, 5, 123, time.FixedZone("CET", 3600))
+	off := on.Add(48 * time.Hour)
+	record := &models.CrewSeatime{
+		ContextID:      "ctx",
+		CrewExternalID: "crew-1",
+		CrewedOn:       &on,
+		CrewedOff:      &off,
+		VesselName:     "Example",
+	}
+
+	dest := filepath.Join(ts.root, "data", "org_seatime.csv")
+	for name, upload := range map[string]func() error{
+		"Upload": func() error { return Upload(context.Background(), c, "org", record) },
+		"UploadIter": func() error {
+			next := record
+			return UploadIter(context.Background(), c, "org", func() (*models.CrewSeatime, error) {
+				if next == nil {
+					return nil, io.EOF
+				}
+				r := next
+				next = nil
+				return r, nil
+			})
+		},
+	} {
+		if err := upload(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		data, err := os.ReadFile(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(data), ",2024-01-02T02:04:05Z,,2024-01-04T02:04:05Z,") {
+			t.Errorf("%s wrote %q, want the crew on and off times in UTC", name, data)
+		}
+	}
+
+	if on.Location().String() != "CET" {
+		t.Error("upload modified the record")
+	}
+}
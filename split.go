@@ -0,0 +1,196 @@
+package sftpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/Maritime-AI/oceo-sftp-csv-go/models"
+	"github.com/gocarina/gocsv"
+)
+
+// partTemplate is inserted before the extension of the name of each part of a
+// split file.
+const partTemplate = "_part%03d"
+
+// WithSplitFiles splits every upload into parts of at most maxRows rows and
+// at most maxBytes bytes of CSV, before compression or encryption. The header
+// counts towards maxBytes. Every part holds at least one row, so a part
+// whose header and single row exceed maxBytes is larger than maxBytes.
+//
+// Each part repeats the header and is named after the file with a "_partNNN"
+// suffix, numbered from 001. Once all parts are uploaded a manifest listing
+// them in order is uploaded, so the server can reassemble the file. Uploads
+// get parts and a manifest even if they fit in a single part.
+//
+// Parameters:
+// - maxRows: The maximum number of rows per part, or 0 for no limit.
+// - maxBytes: The maximum size of a part in bytes, or 0 for no limit.
+//
+// Returns:
+// - An Option that enables splitting.
+func WithSplitFiles(maxRows int, maxBytes int64) Option {
+	return func(s *OCEOSFTPClient) error {
+		if maxRows < 0 || maxBytes < 0 {
+			return errors.New("invalid split limit")
+		}
+
+		if maxRows == 0 && maxBytes == 0 {
+			return errors.New("missing split limit")
+		}
+
+		s.maxRows = maxRows
+		s.maxBytes = maxBytes
+		return nil
+	}
+}
+
+// splitting reports whether uploads are split into parts.
+func (s *OCEOSFTPClient) splitting() bool {
+	return s.maxRows > 0 || s.maxBytes > 0
+}
+
+// fits reports whether a part of rows rows and size bytes has room for
+// another row of rowSize bytes.
+func (s *OCEOSFTPClient) fits(rows int, size, rowSize int64) bool {
+	if s.maxRows > 0 && rows >= s.maxRows {
+		return false
+	}
+	return s.maxBytes == 0 || size+rowSize <= s.maxBytes
+}
+
+// partName inserts the part number before the extension of name.
+func partName(name string, part int) string {
+	ext := path.Ext(name)
+	return name[:len(name)-len(ext)] + fmt.Sprintf(partTemplate, part) + ext
+}
+
+// rowEncoder marshals records to CSV one row at a time.
+type rowEncoder[T models.Record] struct {
+	buf bytes.Buffer
+	cw  *gocsv.SafeCSVWriter
+	row []T
+}
+
+func newRowEncoder[T models.Record]() *rowEncoder[T] {
+	e := &rowEncoder[T]{row: make([]T, 1)}
+	e.cw = gocsv.DefaultCSVWriter(&e.buf)
+	return e
+}
+
+// header returns the header row.
+func (e *rowEncoder[T]) header() ([]byte, error) {
+	e.buf.Reset()
+	var none []T
+	if err := gocsv.MarshalCSV(&none, e.cw); err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", e.row[0].FileType(), err)
+	}
+	return e.buf.Bytes(), nil
+}
+
+// encode returns r as a CSV row. The row is only valid until the next call.
+func (e *rowEncoder[T]) encode(r T) ([]byte, error) {
+	e.buf.Reset()
+	e.row[0] = normalize(r)
+	if err := gocsv.MarshalCSVWithoutHeaders(&e.row, e.cw); err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", r.FileType(), err)
+	}
+	return e.buf.Bytes(), nil
+}
+
+// normalize returns r as it is written to CSV. See models.CSVNormalizer.
+func normalize[T models.Record](r T) T {
+	if n, ok := any(r).(models.CSVNormalizer); ok {
+		return n.NormalizeCSV().(T)
+	}
+	return r
+}
+
+// normalizeAll returns a pointer to records as they are written to CSV,
+// copied only if T is normalized.
+func normalizeAll[T models.Record](records []T) *[]T {
+	var zero T
+	if _, ok := any(zero).(models.CSVNormalizer); !ok {
+		return &records
+	}
+
+	rows := make([]T, len(records))
+	for i, r := range records {
+		rows[i] = normalize(r)
+	}
+	return &rows
+}
+
+// splitFile marshals valid records into parts within the client's limits.
+// name is the base name of the file.
+func splitFile[T models.Record](s *OCEOSFTPClient, name string, records []T) ([]*file, error) {
+	var zero T
+	enc := newRowEncoder[T]()
+	header, err := enc.header()
+	if err != nil {
+		return nil, err
+	}
+	header = bytes.Clone(header)
+
+	var files []*file
+	var buf bytes.Buffer
+	rows := 0
+	flush := func() {
+		files = append(files, &file{
+			fileType: zero.FileType(),
+			name:     s.filePath(zero.FileType(), name, len(files)+1),
+			rows:     rows,
+			data:     bytes.Clone(buf.Bytes()),
+		})
+		buf.Reset()
+		rows = 0
+	}
+
+	for _, r := range records {
+		row, err := enc.encode(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if rows > 0 && !s.fits(rows, int64(buf.Len()), int64(len(row))) {
+			flush()
+		}
+
+		if rows == 0 {
+			buf.Write(header)
+		}
+		buf.Write(row)
+		rows++
+	}
+	flush()
+
+	return files, nil
+}
+
+// uploadFiles uploads files in order and describes each of them.
+func (s *OCEOSFTPClient) uploadFiles(ctx context.Context, orgName string,
+	files []*file) ([]ManifestEntry, error) {
+	entries := make([]ManifestEntry, 0, len(files))
+	for _, f := range files {
+		start := time.Now()
+		dest := s.remotePath(f.name)
+		up, err := s.uploadData(ctx, dest, f.data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload %s: %w", f.name, err)
+		}
+		s.logUpload(ctx, orgName, f.fileType, dest, f.rows, up, start)
+
+		entries = append(entries, ManifestEntry{
+			FileName: f.name,
+			FileType: f.fileType,
+			RowCount: f.rows,
+			ByteSize: up.size,
+			SHA256:   up.sha256,
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,396 @@
+package sftpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Maritime-AI/oceo-sftp-csv-go/models"
+	"github.com/gocarina/gocsv"
+)
+
+// UploadIter validates and uploads the records returned by next, one row at a
+// time, without holding them in memory. next is called until it returns
+// io.EOF; any other error aborts the upload.
+//
+// Unlike Upload, an invalid record is only found once the rows before it have
+// been sent. The upload is then aborted, the partial remote file is removed
+// and an error wrapping a *models.ValidationError for the row is returned.
+// Streamed uploads are not retried, because the records cannot be read a
+// second time. next is never called after UploadIter returns.
+//
+// Parameters:
+// - s: The client to upload with.
+// - orgName: The name of your organization.
+// - next: Returns the next record, or io.EOF once there are no more records.
+//
+// Returns:
+// - An error if reading a record, validation or the upload fails.
+func UploadIter[T models.Record](ctx context.Context, s *OCEOSFTPClient,
+	orgName string, next func() (T, error)) error {
+	return uploadStream(ctx, s, orgName, func(context.Context) (T, error) {
+		return next()
+	})
+}
+
+// UploadChan validates and uploads the records received from records, one row
+// at a time, until the channel is closed. See UploadIter for how streamed
+// uploads differ from Upload.
+//
+// If the upload fails, UploadChan stops receiving. Cancel ctx to stop the
+// goroutine sending the records.
+//
+// Parameters:
+// - s: The client to upload with.
+// - orgName: The name of your organization.
+// - records: The records to upload.
+//
+// Returns:
+// - An error if validation or the upload fails.
+func UploadChan[T models.Record](ctx context.Context, s *OCEOSFTPClient,
+	orgName string, records <-chan T) error {
+	return uploadStream(ctx, s, orgName, func(ctx context.Context) (T, error) {
+		select {
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		case r, ok := <-records:
+			if !ok {
+				return r, io.EOF
+			}
+			return r, nil
+		}
+	})
+}
+
+// CSVOption configures how UploadCSV decodes its input.
+type CSVOption func(*csvOptions)
+
+type csvOptions struct {
+	dateTimes *models.DateTimeDecoding
+}
+
+// WithCSVDateTimeDecoding decodes the date/time columns of the CSV with d
+// instead of the process-wide setting of models.SetDateTimeDecoding, so that
+// sources in different time zones or layouts can be uploaded side by side.
+//
+// Parameters:
+// - d: The decoding settings.
+//
+// Returns:
+// - A CSVOption that sets the date/time decoding.
+func WithCSVDateTimeDecoding(d models.DateTimeDecoding) CSVOption {
+	return func(o *csvOptions) {
+		o.dateTimes = &d
+	}
+}
+
+// UploadCSV decodes CSV rows with the headers of T from r and validates and
+// uploads them one row at a time. See UploadIter for how streamed uploads
+// differ from Upload.
+//
+// Parameters:
+// - s: The client to upload with.
+// - orgName: The name of your organization.
+// - r: The CSV to upload, starting with a header row.
+// - opts: Decoding options, such as WithCSVDateTimeDecoding.
+//
+// Returns:
+// - An error if decoding, validation or the upload fails.
+func UploadCSV[T models.Record](ctx context.Context, s *OCEOSFTPClient,
+	orgName string, r io.Reader, opts ...CSVOption) error {
+	fileType, err := recordFileType[T]()
+	if err != nil {
+		return err
+	}
+
+	var o csvOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.dateTimes != nil {
+		r = newDateTimeReader(r, dateTimeColumns[T](), *o.dateTimes)
+	}
+
+	var zero T
+	um, err := gocsv.NewUnmarshaller(csv.NewReader(r), zero)
+	if errors.Is(err, io.EOF) {
+		s.logNothingToUpload(ctx, orgName, fileType)
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to read %s header: %w", fileType, err)
+	}
+
+	return uploadStream(ctx, s, orgName, func(context.Context) (T, error) {
+		v, err := um.Read()
+		if err != nil {
+			return zero, err
+		}
+		return v.(T), nil
+	})
+}
+
+// uploadStream uploads the records returned by next, in parts if the client
+// splits uploads. The first record is read and validated before connecting,
+// so an empty stream does not create a remote file.
+func uploadStream[T models.Record](ctx context.Context, s *OCEOSFTPClient,
+	orgName string, next func(ctx context.Context) (T, error)) error {
+	fileType, err := recordFileType[T]()
+	if err != nil {
+		return err
+	}
+
+	rs := &recordStream[T]{next: next, enc: newRowEncoder[T](), index: -1}
+	if err := rs.advance(ctx); err != nil {
+		return err
+	}
+
+	if rs.done {
+		s.logNothingToUpload(ctx, orgName, fileType)
+		return nil
+	}
+
+	t := time.Now()
+	name, err := s.baseName(orgName, fileType, t)
+	if err != nil {
+		return err
+	}
+
+	if !s.splitting() {
+		_, err := uploadPart(ctx, s, orgName, rs, s.filePath(fileType, name, 0))
+		return err
+	}
+
+	var entries []ManifestEntry
+	for part := 1; !rs.done; part++ {
+		entry, err := uploadPart(ctx, s, orgName, rs, s.filePath(fileType, name, part))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, *entry)
+	}
+
+	_, err = s.uploadManifest(ctx, orgName, t, entries)
+	return err
+}
+
+// uploadPart uploads the records of rs to the file at rel until the stream
+// ends or the part is full.
+func uploadPart[T models.Record](ctx context.Context, s *OCEOSFTPClient,
+	orgName string, rs *recordStream[T], rel string) (*ManifestEntry, error) {
+	// The records are written from a goroutine fed by the upload. streamCtx
+	// stops a writer waiting on next once the upload is over, and waiting for
+	// done makes sure next is not called after returning.
+	streamCtx, cancel := context.WithCancel(ctx)
+	var rows int
+	var done <-chan struct{}
+	open := func() io.ReadCloser {
+		var r io.ReadCloser
+		r, done = s.encodeFunc(func(w io.Writer) error {
+			var err error
+			rows, err = rs.writePart(streamCtx, w, s.fits)
+			return err
+		})
+		return r
+	}
+
+	start := time.Now()
+	dest := s.remotePath(rel)
+	up, err := s.uploadOnce(ctx, dest, open, false)
+	cancel()
+	if done != nil {
+		<-done
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var zero T
+	s.logUpload(ctx, orgName, zero.FileType(), dest, rows, up, start)
+
+	return &ManifestEntry{
+		FileName: rel,
+		FileType: zero.FileType(),
+		RowCount: rows,
+		ByteSize: up.size,
+		SHA256:   up.sha256,
+	}, nil
+}
+
+// recordStream reads and validates records one ahead of writing them, so it
+// knows whether a part is the last one.
+type recordStream[T models.Record] struct {
+	next func(ctx context.Context) (T, error)
+	enc  *rowEncoder[T]
+	// pending is the next record to write and index its position.
+	pending T
+	index   int
+	// done is set once next has returned io.EOF.
+	done bool
+}
+
+// advance reads and validates the next record.
+func (rs *recordStream[T]) advance(ctx context.Context) error {
+	r, err := rs.next(ctx)
+	if errors.Is(err, io.EOF) {
+		var zero T
+		rs.pending = zero
+		rs.done = true
+		return nil
+	}
+
+	rs.index++
+	if err != nil {
+		return fmt.Errorf("failed to read %s record %d: %w", r.FileType(), rs.index, err)
+	}
+
+	if err := models.ValidateRecord(rs.index, r); err != nil {
+		return err
+	}
+
+	rs.pending = r
+	return nil
+}
+
+// writePart writes a header followed by records to w as CSV until the stream
+// ends or fits reports that the part is full. A part holds at least one row.
+//
+// Returns:
+// - The number of rows written.
+func (rs *recordStream[T]) writePart(ctx context.Context, w io.Writer,
+	fits func(rows int, size, rowSize int64) bool) (int, error) {
+	bw := bufio.NewWriter(w)
+	header, err := rs.enc.header()
+	if err != nil {
+		return 0, err
+	}
+
+	size, _ := bw.Write(header)
+	rows := 0
+	for !rs.done {
+		row, err := rs.enc.encode(rs.pending)
+		if err != nil {
+			return rows, err
+		}
+
+		if rows > 0 && !fits(rows, int64(size), int64(len(row))) {
+			break
+		}
+
+		n, err := bw.Write(row)
+		if err != nil {
+			return rows, err
+		}
+		size += n
+		rows++
+
+		if err := rs.advance(ctx); err != nil {
+			return rows, err
+		}
+	}
+
+	return rows, bw.Flush()
+}
+
+var dateTimeType = reflect.TypeOf(models.DateTime{})
+
+// dateTimeColumns returns the CSV headers of the DateTime fields of T.
+func dateTimeColumns[T models.Record]() map[string]bool {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	columns := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		if ft == dateTimeType {
+			name, _, _ := strings.Cut(f.Tag.Get("csv"), ",")
+			columns[name] = true
+		}
+	}
+
+	return columns
+}
+
+// dateTimeReader rewrites the date/time columns of a CSV in DateTimeFormat,
+// decoding them with its own settings. It reads one row at a time.
+type dateTimeReader struct {
+	src     *csv.Reader
+	columns map[string]bool
+	dec     models.DateTimeDecoding
+	// indexes are the positions of the date/time columns, known once the
+	// header has been read.
+	indexes []int
+	header  []string
+	buf     bytes.Buffer
+	w       *csv.Writer
+	err     error
+}
+
+func newDateTimeReader(r io.Reader, columns map[string]bool, dec models.DateTimeDecoding) *dateTimeReader {
+	d := &dateTimeReader{src: csv.NewReader(r), columns: columns, dec: dec}
+	d.w = csv.NewWriter(&d.buf)
+	return d
+}
+
+func (d *dateTimeReader) Read(p []byte) (int, error) {
+	for d.buf.Len() == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.next()
+	}
+	return d.buf.Read(p)
+}
+
+// next reads, converts and buffers one row.
+func (d *dateTimeReader) next() error {
+	row, err := d.src.Read()
+	if err != nil {
+		return err
+	}
+
+	if d.header == nil {
+		d.header = row
+		for i, name := range row {
+			if d.columns[strings.TrimSpace(name)] {
+				d.indexes = append(d.indexes, i)
+			}
+		}
+	} else {
+		for _, i := range d.indexes {
+			if i >= len(row) {
+				continue
+			}
+
+			dt, err := d.dec.Parse(row[i])
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", d.header[i], err)
+			}
+			row[i] = dt.String()
+		}
+	}
+
+	if err := d.w.Write(row); err != nil {
+		return err
+	}
+	d.w.Flush()
+	return d.w.Error()
+}
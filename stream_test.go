@@ -0,0 +1,333 @@
+package sftpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Maritime-AI/oceo-sftp-csv-go/models"
+	"github.com/gocarina/gocsv"
+)
+
+// crewIter returns an iterator over n test crew members.
+func crewIter(n int) func() (*models.Crew, error) {
+	i := 0
+	return func() (*models.Crew, error) {
+		if i == n {
+			return nil, io.EOF
+		}
+		i++
+		return testCrew(i), nil
+	}
+}
+
+// crewCSV returns n test crew members as CSV.
+func crewCSV(tb testing.TB, n int) []byte {
+	tb.Helper()
+
+	records := make([]*models.Crew, n)
+	for i := range records {
+		records[i] = testCrew(i + 1)
+	}
+
+	data, err := gocsv.MarshalBytes(records)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
+
+func TestStreamingUploadsMatchUpload(t *testing.T) {
+	const rows = 100
+
+	ts := newTestServer(t)
+	c := ts.client(t, WithFileNameFunc(fixedFileName))
+	ctx := context.Background()
+	dest := filepath.Join(ts.root, "data", "org_crew.csv")
+
+	records := make([]*models.Crew, rows)
+	for i := range records {
+		records[i] = testCrew(i + 1)
+	}
+
+	if err := Upload(ctx, c, "org", records...); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, upload := range map[string]func() error{
+		"UploadIter": func() error { return UploadIter(ctx, c, "org", crewIter(rows)) },
+		"UploadChan": func() error {
+			ch := make(chan *models.Crew)
+			go func() {
+				defer close(ch)
+				for _, r := range records {
+					ch <- r
+				}
+			}()
+			return UploadChan(ctx, c, "org", ch)
+		},
+		"UploadCSV": func() error {
+			return UploadCSV[*models.Crew](ctx, c, "org", bytes.NewReader(crewCSV(t, rows)))
+		},
+	} {
+		if err := os.Remove(dest); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := upload(); err != nil {
+			t.Errorf("%s failed: %v", name, err)
+			continue
+		}
+
+		got, err := os.ReadFile(dest)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s uploaded\n%s\nwant\n%s", name, got, want)
+		}
+	}
+}
+
+func TestStreamingUploadInvalidRecord(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t)
+
+	next := crewIter(50)
+	invalid := 0
+	err := UploadIter(context.Background(), c, "org", func() (*models.Crew, error) {
+		r, err := next()
+		if r != nil && r.CrewExternalID == "crew-40" {
+			r.FirstName = ""
+			invalid++
+		}
+		return r, err
+	})
+
+	var ve *models.ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("UploadIter returned %v, want a *models.ValidationError", err)
+	}
+
+	if invalid != 1 {
+		t.Fatalf("read the invalid record %d times, want 1", invalid)
+	}
+
+	files, err := os.ReadDir(filepath.Join(ts.root, "data"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		t.Errorf("aborted upload left %s", f.Name())
+	}
+}
+
+func TestStreamingUploadSplit(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, WithSplitFiles(10, 0))
+
+	if err := UploadIter(context.Background(), c, "org", crewIter(25)); err != nil {
+		t.Fatal(err)
+	}
+
+	parts, err := filepath.Glob(filepath.Join(ts.root, "data", "*_part*.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parts) != 3 {
+		t.Fatalf("uploaded parts %v, want 3", parts)
+	}
+}
+
+func TestSplitByteLimitCountsHeader(t *testing.T) {
+	// A header and one row fit exactly, so every row gets a part of its own.
+	// A limit below that still gives every part one row.
+	one := int64(len(crewCSV(t, 1)))
+	for _, maxBytes := range []int64{one, one + 1, 1} {
+		ts := newTestServer(t)
+		c := ts.client(t, WithSplitFiles(0, maxBytes), WithFileNameFunc(fixedFileName))
+
+		if err := Upload(context.Background(), c, "org", testCrew(1), testCrew(2), testCrew(3)); err != nil {
+			t.Fatal(err)
+		}
+
+		parts, err := filepath.Glob(filepath.Join(ts.root, "data", "*_part*.csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(parts) != 3 {
+			t.Errorf("maxBytes %d: uploaded parts %v, want 3", maxBytes, parts)
+		}
+	}
+}
+
+// benchmarkRows are the upload sizes of the streaming benchmarks.
+var benchmarkRows = []int{1000, 10000, 100000}
+
+// benchmarkUpload runs upload for each of benchmarkRows. Besides allocations
+// it reports peak-heap-B, the largest live heap seen during the run, which
+// stays flat as the row count grows for the streaming uploads. The heap
+// includes the benchmark's own input, e.g. the CSV of BenchmarkUploadCSV.
+func benchmarkUpload(b *testing.B, upload func(c *OCEOSFTPClient, rows int) error) {
+	ts := newTestServer(b)
+	c := ts.client(b, WithFileNameFunc(fixedFileName))
+
+	for _, rows := range benchmarkRows {
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			runtime.GC()
+			stop := samplePeakHeap()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := upload(c, rows); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(stop()), "peak-heap-B")
+		})
+	}
+}
+
+// samplePeakHeap samples the live heap until the returned func is called,
+// which returns the largest sample.
+func samplePeakHeap() func() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	done := make(chan struct{})
+	peak := make(chan uint64)
+
+	go func() {
+		var max uint64
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			metrics.Read(sample)
+			if v := sample[0].Value.Uint64(); v > max {
+				max = v
+			}
+
+			select {
+			case <-done:
+				peak <- max
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() uint64 {
+		close(done)
+		return <-peak
+	}
+}
+
+func BenchmarkUpload(b *testing.B) {
+	benchmarkUpload(b, func(c *OCEOSFTPClient, rows int) error {
+		records := make([]*models.Crew, rows)
+		for i := range records {
+			records[i] = testCrew(i + 1)
+		}
+		return Upload(context.Background(), c, "org", records...)
+	})
+}
+
+func BenchmarkUploadIter(b *testing.B) {
+	benchmarkUpload(b, func(c *OCEOSFTPClient, rows int) error {
+		return UploadIter(context.Background(), c, "org", crewIter(rows))
+	})
+}
+
+func BenchmarkUploadChan(b *testing.B) {
+	benchmarkUpload(b, func(c *OCEOSFTPClient, rows int) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ch := make(chan *models.Crew)
+		go func() {
+			defer close(ch)
+			next := crewIter(rows)
+			for {
+				r, err := next()
+				if err != nil {
+					return
+				}
+				select {
+				case ch <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return UploadChan(ctx, c, "org", ch)
+	})
+}
+
+func BenchmarkUploadCSV(b *testing.B) {
+	data := make(map[int][]byte, len(benchmarkRows))
+	for _, rows := range benchmarkRows {
+		data[rows] = crewCSV(b, rows)
+	}
+
+	benchmarkUpload(b, func(c *OCEOSFTPClient, rows int) error {
+		return UploadCSV[*models.Crew](context.Background(), c, "org", bytes.NewReader(data[rows]))
+	})
+}
+
+func TestUploadCSVDateTimeDecoding(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, WithFileNameFunc(fixedFileName))
+
+	const in = "Context ID,External ID,Vessel External ID,Vessel Name,Service Start At,Service End At\n" +
+		"ctx,vs-1,v-1,Example,03/04/2024,03/04/2024 12:00:00\n"
+
+	sydney := time.FixedZone("UTC+10", 10*3600)
+	for _, tt := range []struct {
+		org  string
+		dec  models.DateTimeDecoding
+		want string
+	}{
+		{"us", models.DateTimeDecoding{Layouts: models.MonthFirstLayouts},
+			"2024-03-04T00:00:00Z,2024-03-04T12:00:00Z"},
+		{"au", models.DateTimeDecoding{Location: sydney, Layouts: models.DayFirstLayouts},
+			"2024-04-02T14:00:00Z,2024-04-03T02:00:00Z"},
+	} {
+		err := UploadCSV[*models.VesselSchedule](context.Background(), c, tt.org,
+			strings.NewReader(in), WithCSVDateTimeDecoding(tt.dec))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.org, err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(ts.root, "data", tt.org+"_vesselschedules.csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(data), tt.want) {
+			t.Errorf("%s: uploaded %q, want service times %s", tt.org, data, tt.want)
+		}
+	}
+
+	// Without the option the slash dates are rejected.
+	err := UploadCSV[*models.VesselSchedule](context.Background(), c, "org", strings.NewReader(in))
+	if err == nil {
+		t.Error("UploadCSV accepted slash dates without a decoding option")
+	}
+}
@@ -0,0 +1,177 @@
+package sftpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultDialTimeout      = 30 * time.Second
+	defaultHandshakeTimeout = 30 * time.Second
+	defaultIdleTimeout      = 2 * time.Minute
+)
+
+// ErrTimeout is matched by errors.Is when the server stopped responding, i.e.
+// a dial, handshake, remote write or keepalive took longer than its timeout.
+// Timeouts are retryable, see IsRetryable.
+var ErrTimeout = errors.New("SFTP server timed out")
+
+// WithDialTimeout bounds how long opening a TCP connection may take, both to
+// the server and to each jump host. A non-positive timeout disables it.
+//
+// Parameters:
+// - timeout: The dial timeout. Defaults to 30 seconds.
+//
+// Returns:
+// - An Option that sets the dial timeout.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(s *OCEOSFTPClient) error {
+		s.dialTimeout = timeout
+		return nil
+	}
+}
+
+// WithHandshakeTimeout bounds how long the SSH handshake, including
+// authentication and starting the SFTP session, may take. It applies to jump
+// hosts too. A non-positive timeout disables it.
+//
+// Parameters:
+// - timeout: The handshake timeout. Defaults to 30 seconds.
+//
+// Returns:
+// - An Option that sets the handshake timeout.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(s *OCEOSFTPClient) error {
+		s.handshakeTimeout = timeout
+		return nil
+	}
+}
+
+// WithIdleTimeout fails an upload once the server has not accepted any data
+// or answered any request for timeout, and closes the connection. Time spent
+// waiting for the records of a streamed upload does not count. A non-positive
+// timeout disables it.
+//
+// Parameters:
+// - timeout: The idle timeout. Defaults to 2 minutes.
+//
+// Returns:
+// - An Option that sets the idle timeout.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(s *OCEOSFTPClient) error {
+		s.idleTimeout = timeout
+		return nil
+	}
+}
+
+// timeoutError reports that op did not finish within d. It is a net.Error
+// whose Timeout method returns true, so IsRetryable retries it.
+type timeoutError struct {
+	op string
+	d  time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.op, e.d)
+}
+
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+func (e *timeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// dialTimeout dials addr with d, giving up after timeout.
+func dialTimeout(ctx context.Context, d Dialer, addr string, timeout time.Duration) (net.Conn, error) {
+	if timeout <= 0 {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+
+	dctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	nc, err := d.DialContext(dctx, "tcp", addr)
+	if err != nil && ctx.Err() == nil && dctx.Err() != nil {
+		return nil, &timeoutError{op: "dial " + addr, d: timeout}
+	}
+	return nc, err
+}
+
+// watchdog calls fire once it has not been reset for its timeout. It enforces
+// timeouts on connections that may not support deadlines, such as those
+// tunnelled through a jump host. A nil watchdog never fires.
+type watchdog struct {
+	op    string
+	d     time.Duration
+	t     *time.Timer
+	fired atomic.Bool
+}
+
+// startWatchdog starts a watchdog for op, or returns nil if d is not positive.
+func startWatchdog(op string, d time.Duration, fire func(err error)) *watchdog {
+	if d <= 0 {
+		return nil
+	}
+
+	w := &watchdog{op: op, d: d}
+	w.t = time.AfterFunc(d, func() {
+		w.fired.Store(true)
+		fire(w.err())
+	})
+	return w
+}
+
+// reset restarts the countdown unless the watchdog has already fired.
+func (w *watchdog) reset() {
+	if w != nil && !w.fired.Load() {
+		w.t.Reset(w.d)
+	}
+}
+
+// pause stops the countdown until the next reset.
+func (w *watchdog) pause() {
+	if w != nil {
+		w.t.Stop()
+	}
+}
+
+// stop stops the watchdog and reports whether it had not fired.
+func (w *watchdog) stop() bool {
+	if w == nil {
+		return true
+	}
+	w.t.Stop()
+	return !w.fired.Load()
+}
+
+// check returns the timeout error in place of err if the watchdog has fired.
+func (w *watchdog) check(err error) error {
+	if w != nil && w.fired.Load() {
+		return w.err()
+	}
+	return err
+}
+
+func (w *watchdog) err() error {
+	return &timeoutError{op: w.op, d: w.d}
+}
+
+// idleReader pauses w while waiting for r, so only time spent waiting on the
+// server counts towards the idle timeout.
+type idleReader struct {
+	r io.Reader
+	w *watchdog
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	r.w.pause()
+	n, err := r.r.Read(p)
+	r.w.reset()
+	return n, err
+}
@@ -0,0 +1,269 @@
+package sftpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Maritime-AI/oceo-sftp-csv-go/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// timeoutBound is how long a test waits for a timeout of at most a few
+// hundred milliseconds to fail an upload.
+const timeoutBound = 5 * time.Second
+
+// blackHole is a TCP proxy to target that can be made to silently drop
+// everything in both directions, like a dead link that does not reset
+// connections. Without a target it drops everything from the start.
+type blackHole struct {
+	addr    string
+	target  string
+	dropped chan struct{}
+	once    sync.Once
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newBlackHole(t *testing.T, target string) *blackHole {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &blackHole{addr: l.Addr().String(), target: target, dropped: make(chan struct{})}
+	if target == "" {
+		h.drop()
+	}
+
+	t.Cleanup(func() {
+		_ = l.Close()
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for _, c := range h.conns {
+			_ = c.Close()
+		}
+	})
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			h.track(conn)
+			go h.serve(conn)
+		}
+	}()
+
+	return h
+}
+
+// drop makes the black hole stop forwarding on all connections.
+func (h *blackHole) drop() {
+	h.once.Do(func() { close(h.dropped) })
+}
+
+func (h *blackHole) track(c net.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.conns = append(h.conns, c)
+}
+
+func (h *blackHole) serve(conn net.Conn) {
+	if h.target == "" {
+		_, _ = io.Copy(io.Discard, conn)
+		return
+	}
+
+	target, err := net.Dial("tcp", h.target)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	h.track(target)
+
+	go h.forward(target, conn)
+	h.forward(conn, target)
+}
+
+// forward copies from src to dst until the black hole drops, and discards
+// everything read from src after that.
+func (h *blackHole) forward(dst io.Writer, src io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		select {
+		case <-h.dropped:
+		default:
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// checkTimeout checks that err is a retryable ErrTimeout mentioning op that
+// was returned within timeoutBound of start.
+func checkTimeout(t *testing.T, start time.Time, err error, op string) {
+	t.Helper()
+
+	if elapsed := time.Since(start); elapsed > timeoutBound {
+		t.Errorf("upload failed after %s, want less than %s", elapsed, timeoutBound)
+	}
+
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("upload returned %v, want ErrTimeout", err)
+	}
+
+	if !IsRetryable(err) {
+		t.Errorf("IsRetryable(%v) = false, want true", err)
+	}
+
+	if !strings.Contains(err.Error(), op) {
+		t.Errorf("upload returned %v, want a timeout of %s", err, op)
+	}
+}
+
+// droppingIter returns n test crew members, dropping h once the upload has
+// started streaming them. The first record is read before connecting.
+func droppingIter(h *blackHole, n int) func() (*models.Crew, error) {
+	next := crewIter(n)
+	calls := 0
+	return func() (*models.Crew, error) {
+		if calls++; calls == 2 {
+			h.drop()
+		}
+		return next()
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	ts := newTestServer(t)
+
+	// The dialer hangs like a connection attempt whose packets are dropped.
+	hung := DialerFunc(func(ctx context.Context, _, _ string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	for name, opt := range map[string]Option{
+		"dialer":     WithDialer(hung),
+		"http proxy": WithHTTPProxy(newBlackHole(t, "").addr, nil),
+		"socks5":     WithSOCKS5Proxy(newBlackHole(t, "").addr, nil),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := proxyClient(t, ts, ts.host, ts.port, opt, WithDialTimeout(200*time.Millisecond))
+
+			start := time.Now()
+			err := Upload(context.Background(), c, "org", testCrew(1))
+			checkTimeout(t, start, err, "dial")
+		})
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	ts := newTestServer(t)
+	h := newBlackHole(t, "")
+	host, port, _ := net.SplitHostPort(h.addr)
+
+	c := proxyClient(t, ts, host, port, WithHandshakeTimeout(200*time.Millisecond))
+
+	start := time.Now()
+	err := Upload(context.Background(), c, "org", testCrew(1))
+	checkTimeout(t, start, err, "SSH handshake")
+}
+
+func TestJumpHostHandshakeTimeout(t *testing.T) {
+	ts := newTestServer(t)
+	jump := newBlackHole(t, "")
+
+	hop := ts.jumpHost(t, ssh.InsecureIgnoreHostKey())
+	hop.Addr = jump.addr
+	c := ts.client(t, WithJumpHost(hop), noRetry, WithHandshakeTimeout(200*time.Millisecond))
+
+	start := time.Now()
+	err := Upload(context.Background(), c, "org", testCrew(1))
+	checkTimeout(t, start, err, "SSH handshake with jump host")
+}
+
+func TestIdleTimeout(t *testing.T) {
+	ts := newTestServer(t)
+	h := newBlackHole(t, ts.addr)
+	host, port, _ := net.SplitHostPort(h.addr)
+
+	c := proxyClient(t, ts, host, port, WithKeepAlive(0), WithIdleTimeout(300*time.Millisecond))
+
+	start := time.Now()
+	err := UploadIter(context.Background(), c, "org", droppingIter(h, 1000))
+	checkTimeout(t, start, err, "SFTP server response")
+}
+
+func TestIdleTimeoutIgnoresSlowRecords(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.client(t, WithIdleTimeout(100*time.Millisecond))
+
+	// Producing the records takes longer than the idle timeout, but the
+	// server answers promptly.
+	next := crewIter(3)
+	err := UploadIter(context.Background(), c, "org", func() (*models.Crew, error) {
+		time.Sleep(150 * time.Millisecond)
+		return next()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeepAliveTimeout(t *testing.T) {
+	ts := newTestServer(t)
+	h := newBlackHole(t, ts.addr)
+	host, port, _ := net.SplitHostPort(h.addr)
+
+	c := proxyClient(t, ts, host, port, WithKeepAlive(200*time.Millisecond), WithIdleTimeout(0))
+
+	// Without an idle timeout only the unanswered keepalive ends the upload.
+	start := time.Now()
+	err := UploadIter(context.Background(), c, "org", droppingIter(h, 1000))
+	checkTimeout(t, start, err, "SFTP keepalive")
+}
+
+func TestKeepAliveClosesDeadSession(t *testing.T) {
+	ts := newTestServer(t)
+	h := newBlackHole(t, ts.addr)
+	host, port, _ := net.SplitHostPort(h.addr)
+
+	c := proxyClient(t, ts, host, port, WithKeepAlive(100*time.Millisecond))
+	ctx := context.Background()
+	if err := Upload(ctx, c, "org", testCrew(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	c.mu.Lock()
+	sess := c.sess
+	c.mu.Unlock()
+
+	h.drop()
+
+	select {
+	case <-sess.done:
+	case <-time.After(timeoutBound):
+		t.Fatal("keepalive did not close the dead session")
+	}
+
+	if err := sess.failure(); !errors.Is(err, ErrTimeout) || !IsRetryable(err) {
+		t.Errorf("session failed with %v, want a retryable ErrTimeout", err)
+	}
+}